@@ -0,0 +1,97 @@
+package wlog
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Clock tells the time. SetClock replaces the system clock, to drive
+// timestamps, rotation and cleanup from tests.
+type Clock interface {
+	Now() time.Time
+}
+
+// TimerClock is a Clock that also runs timers. A fake one lets tests fire
+// the daily rotation and cleanup and the rate limit notice without
+// waiting; with a plain Clock they run on the system clock.
+type TimerClock interface {
+	Clock
+	// After returns a channel that receives the time once d has passed.
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// clockSetter is implemented by adapters that read the time themselves.
+type clockSetter interface {
+	setClock(c Clock)
+}
+
+// clockRef holds a Clock that can be replaced while in use.
+type clockRef struct {
+	p atomic.Pointer[Clock]
+
+	mu      sync.Mutex
+	changed chan struct{} // closed by the next set
+}
+
+func (r *clockRef) set(c Clock) {
+	r.p.Store(&c)
+	r.mu.Lock()
+	if r.changed != nil {
+		close(r.changed)
+		r.changed = nil
+	}
+	r.mu.Unlock()
+}
+
+// onChange returns a channel closed when the clock is next replaced, so
+// that timers started on the old one can be started again.
+func (r *clockRef) onChange() <-chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.changed == nil {
+		r.changed = make(chan struct{})
+	}
+	return r.changed
+}
+
+func (r *clockRef) now() time.Time {
+	if c := r.p.Load(); c != nil {
+		return (*c).Now()
+	}
+	return time.Now()
+}
+
+// after returns a channel that receives the time once d has passed, and
+// a function releasing it early.
+func (r *clockRef) after(d time.Duration) (<-chan time.Time, func()) {
+	if c := r.p.Load(); c != nil {
+		if tc, ok := (*c).(TimerClock); ok {
+			return tc.After(d), func() {}
+		}
+	}
+	t := time.NewTimer(d)
+	return t.C, func() { t.Stop() }
+}
+
+// SetClock makes the logger and its outputs, including those added later,
+// take the time from c. A nil c restores the system clock.
+func (bl *WLogger) SetClock(c Clock) {
+	if c == nil {
+		c = systemClock{}
+	}
+	bl.lock.Lock()
+	defer bl.lock.Unlock()
+	bl.clock.set(c)
+	for _, l := range bl.loadOutputs() {
+		if cs, ok := l.Logger.(clockSetter); ok {
+			cs.setClock(c)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package wlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// configurer is implemented by the adapters whose Init unmarshals the
+// config into the adapter itself and then calls setup, so that a config
+// can be decoded into them by other means.
+type configurer interface {
+	setup() error
+}
+
+// SetLoggerMap adds an output as SetLogger does, taking the config as a map
+// with the keys of its JSON form, e.g. {"filename": "app.log", "maxsize":
+// "10MB"}. The built-in adapters read the map directly; adapters added
+// with Register get it as JSON.
+func (bl *WLogger) SetLoggerMap(adapterName string, config map[string]interface{}) error {
+	return bl.addLogger(adapterName, adapterName, config)
+}
+
+// initLogger sets lg up from config, a JSON string, a map or a FileConfig.
+func initLogger(lg Logger, config interface{}) error {
+	switch config := config.(type) {
+	case string:
+		return lg.Init(config)
+	case map[string]interface{}:
+		c, ok := lg.(configurer)
+		if !ok {
+			b, err := json.Marshal(config)
+			if err != nil {
+				return err
+			}
+			return lg.Init(string(b))
+		}
+		if err := decodeMap(config, lg); err != nil {
+			return err
+		}
+		return c.setup()
+	case FileConfig:
+		w, ok := lg.(*fileLogWriter)
+		if !ok {
+			return fmt.Errorf("FileConfig given to a %T", lg)
+		}
+		w.FileConfig = config
+		return w.setup()
+	default:
+		return fmt.Errorf("unsupported config type %T", config)
+	}
+}
+
+// readLevelRange reads "minlevel" and "maxlevel" from config.
+func readLevelRange(config interface{}) (levelRange, error) {
+	lr := levelRange{MinLevel: math.MinInt, MaxLevel: math.MaxInt}
+	var err error
+	switch config := config.(type) {
+	case string:
+		if len(config) > 0 {
+			err = json.Unmarshal([]byte(config), &lr)
+		}
+	case map[string]interface{}:
+		err = decodeMap(config, &lr)
+	}
+	return lr, err
+}
+
+var byteSizeType = reflect.TypeOf(ByteSize(0))
+
+// decodeMap sets the fields of the struct dst points to from m, matching
+// keys to fields as encoding/json does: by json tag or field name, ignoring
+// case, through embedded structs. Keys matching no field are ignored.
+func decodeMap(m map[string]interface{}, dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("cannot decode config into %T", dst)
+	}
+	for key, val := range m {
+		f, ok := findField(v.Elem(), key)
+		if !ok {
+			continue
+		}
+		if err := setValue(f, val); err != nil {
+			return fmt.Errorf("config %q: %s", key, err)
+		}
+	}
+	return nil
+}
+
+// findField returns the settable field of struct v named key. A field
+// whose name matches exactly wins over one matching without case.
+func findField(v reflect.Value, key string) (reflect.Value, bool) {
+	var fold reflect.Value
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if sf.Anonymous && name == "" && sf.Type.Kind() == reflect.Struct {
+			if f, ok := findField(v.Field(i), key); ok {
+				return f, true
+			}
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		if name == key {
+			return v.Field(i), true
+		}
+		if !fold.IsValid() && strings.EqualFold(name, key) {
+			fold = v.Field(i)
+		}
+	}
+	return fold, fold.IsValid()
+}
+
+// setValue stores val in f, converting between the kinds of numbers and
+// accepting "10MB" style strings for a ByteSize. A nil val clears pointers
+// and maps and leaves other fields as they are.
+func setValue(f reflect.Value, val interface{}) error {
+	if val == nil {
+		switch f.Kind() {
+		case reflect.Pointer, reflect.Map, reflect.Interface, reflect.Slice:
+			f.Set(reflect.Zero(f.Type()))
+		}
+		return nil
+	}
+	rv := reflect.ValueOf(val)
+	if rv.Type().AssignableTo(f.Type()) {
+		f.Set(rv)
+		return nil
+	}
+
+	if f.Type() == byteSizeType && rv.Kind() == reflect.String {
+		size, err := ParseByteSize(rv.String())
+		if err != nil {
+			return err
+		}
+		f.SetInt(int64(size))
+		return nil
+	}
+
+	switch f.Kind() {
+	case reflect.Pointer:
+		p := reflect.New(f.Type().Elem())
+		if err := setValue(p.Elem(), val); err != nil {
+			return err
+		}
+		f.Set(p)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := toInt(rv)
+		if !ok || f.OverflowInt(n) {
+			break
+		}
+		f.SetInt(n)
+		return nil
+	case reflect.String:
+		if rv.Kind() == reflect.String {
+			f.SetString(rv.String())
+			return nil
+		}
+	case reflect.Bool:
+		if rv.Kind() == reflect.Bool {
+			f.SetBool(rv.Bool())
+			return nil
+		}
+	case reflect.Map:
+		if rv.Kind() != reflect.Map || f.Type().Key().Kind() != reflect.String {
+			break
+		}
+		out := reflect.MakeMapWithSize(f.Type(), rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			elem := reflect.New(f.Type().Elem()).Elem()
+			if err := setValue(elem, iter.Value().Interface()); err != nil {
+				return err
+			}
+			out.SetMapIndex(reflect.ValueOf(fmt.Sprint(iter.Key().Interface())).Convert(f.Type().Key()), elem)
+		}
+		f.Set(out)
+		return nil
+	}
+	return fmt.Errorf("cannot use %T as %s", val, f.Type())
+}
+
+// toInt converts an integer or a float without a fractional part.
+func toInt(v reflect.Value) (int64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if f != math.Trunc(f) || f < math.MinInt64 || f > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(f), true
+	}
+	return 0, false
+}
@@ -0,0 +1,71 @@
+package wlog
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDecodeMap(t *testing.T) {
+	tests := []struct {
+		name   string
+		config map[string]interface{}
+		check  func(w *fileLogWriter) bool
+		err    string
+	}{
+		{"json numbers", map[string]interface{}{"maxlines": 100.0, "maxdays": 3}, func(w *fileLogWriter) bool { return w.MaxLines == 100 }, ""},
+		{"int", map[string]interface{}{"maxlines": int64(100)}, func(w *fileLogWriter) bool { return w.MaxLines == 100 }, ""},
+		{"size string", map[string]interface{}{"maxsize": "10MB"}, func(w *fileLogWriter) bool { return w.MaxSize == 10<<20 }, ""},
+		{"size number", map[string]interface{}{"maxsize": 2048}, func(w *fileLogWriter) bool { return w.MaxSize == 2048 }, ""},
+		{"case", map[string]interface{}{"MaxLines": 7}, func(w *fileLogWriter) bool { return w.MaxLines == 7 }, ""},
+		{"pointer", map[string]interface{}{"synclevel": LevelError}, func(w *fileLogWriter) bool { return w.SyncLevel != nil && *w.SyncLevel == LevelError }, ""},
+		{"embedded", map[string]interface{}{"format": "json"}, func(w *fileLogWriter) bool { return w.Format == FormatJSON }, ""},
+		{"bool", map[string]interface{}{"daily": false}, func(w *fileLogWriter) bool { return !w.Daily }, ""},
+		{"fraction", map[string]interface{}{"maxlines": 1.5}, nil, `"maxlines"`},
+		{"wrong type", map[string]interface{}{"daily": "yes"}, nil, `"daily"`},
+		{"bad size", map[string]interface{}{"maxsize": "lots"}, nil, `"maxsize"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := map[string]interface{}{"filename": filepath.Join(t.TempDir(), "app.log")}
+			for k, v := range tt.config {
+				config[k] = v
+			}
+			w := newFileWriter().(*fileLogWriter)
+			err := initLogger(w, config)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("error %v, want one about %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer w.Destroy()
+			if !tt.check(w) {
+				t.Errorf("config %v not applied: %+v", tt.config, w.FileConfig)
+			}
+		})
+	}
+}
+
+// TestSetLoggerMap sets up a file output from a map and logs through it.
+func TestSetLoggerMap(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	bl := NewLogger()
+	err := bl.SetLoggerMap(AdapterFile, map[string]interface{}{
+		"filename": name,
+		"level":    LevelWarning,
+		"daily":    false,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bl.Info("dropped")
+	bl.Warn("kept")
+	bl.Close()
+	if s := readFile(t, name); strings.Contains(s, "dropped") || !strings.Contains(s, "[W] kept") {
+		t.Errorf("app.log = %q, want the warning alone", s)
+	}
+}
@@ -0,0 +1,196 @@
+package wlog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	connRetryDelay    = 100 * time.Millisecond
+	connMaxRetryDelay = 30 * time.Second
+	connProbeTimeout  = 10 * time.Millisecond
+)
+
+// connWriter writes each line to a network connection. A write failing on
+// an open connection is retried once on a fresh one. When the line cannot
+// be sent it goes to stderr and the error is returned. After a failed dial
+// no other is tried for a cool-down, doubling from connRetryDelay up to
+// connMaxRetryDelay while the peer stays down, so that callers are not held
+// up dialling for every message.
+type connWriter struct {
+	sync.Mutex
+	conn       net.Conn
+	destroyed  bool // set by Destroy, no connection is dialled after it
+	fallback   *logWriter
+	formatter  Formatter
+	retryAt    time.Time     // no dial before it
+	retryDelay time.Duration // the last cool-down, 0 after a good dial
+
+	ReconnectOnMsg bool   `json:"reconnectOnMsg"`
+	Net            string `json:"net"`
+	Address        string `json:"address"`
+	DialTimeout    int    `json:"dialtimeout"`  // milliseconds, 0 means none
+	WriteTimeout   int    `json:"writetimeout"` // milliseconds, 0 means none
+	Level          int    `json:"level"`
+	formatConfig
+}
+
+func newConnWriter() Logger {
+	return &connWriter{
+		fallback:    newLogWriter(os.Stderr),
+		formatter:   TextFormatter{},
+		Net:         "tcp",
+		DialTimeout: 1000,
+		Level:       LevelTrace,
+	}
+}
+
+func (c *connWriter) Init(jsonConfig string) error {
+	if len(jsonConfig) > 0 {
+		if err := json.Unmarshal([]byte(jsonConfig), c); err != nil {
+			return err
+		}
+	}
+	return c.setup()
+}
+
+func (c *connWriter) setup() error {
+	if c.Address == "" {
+		return errors.New("conn: must have address")
+	}
+
+	var err error
+	c.formatter, err = c.newFormatter()
+	return err
+}
+
+func (c *connWriter) enabled(level int) bool {
+	return !filtered(level, c.Level)
+}
+
+func (c *connWriter) WriteMsg(when time.Time, msg string, level int) error {
+	return c.writeRecord(newTextRecord(when, msg, level))
+}
+
+func (c *connWriter) writeRecord(r *Record) error {
+	if filtered(r.Level, c.Level) {
+		return nil
+	}
+
+	line := formatRecord(c.formatter, r)
+	line = append(line, c.lineSeparator()...)
+
+	c.Lock()
+	defer c.Unlock()
+	if c.destroyed {
+		return os.ErrClosed
+	}
+	if c.ReconnectOnMsg {
+		defer c.close()
+	}
+
+	wasOpen := c.conn != nil
+	err := c.write(line)
+	if err != nil && wasOpen {
+		// The peer may have dropped an idle connection.
+		c.close()
+		err = c.write(line)
+	}
+	if err != nil {
+		c.close()
+		c.fallback.writeln(line, "")
+	}
+	return err
+}
+
+// dial connects to the address unless a cool-down from an earlier failed
+// dial is running.
+func (c *connWriter) dial() (net.Conn, error) {
+	now := time.Now()
+	if now.Before(c.retryAt) {
+		return nil, fmt.Errorf("conn: %s unreachable, next dial in %s", c.Address, c.retryAt.Sub(now).Round(time.Millisecond))
+	}
+	conn, err := net.DialTimeout(c.Net, c.Address, time.Duration(c.DialTimeout)*time.Millisecond)
+	if err != nil {
+		c.retryDelay *= 2
+		if c.retryDelay < connRetryDelay {
+			c.retryDelay = connRetryDelay
+		} else if c.retryDelay > connMaxRetryDelay {
+			c.retryDelay = connMaxRetryDelay
+		}
+		c.retryAt = now.Add(c.retryDelay)
+		return nil, err
+	}
+	c.retryDelay = 0
+	return conn, nil
+}
+
+func (c *connWriter) write(line []byte) error {
+	if c.conn == nil {
+		conn, err := c.dial()
+		if err != nil {
+			return err
+		}
+		c.conn = conn
+	}
+	if c.WriteTimeout > 0 {
+		c.conn.SetWriteDeadline(time.Now().Add(time.Duration(c.WriteTimeout) * time.Millisecond))
+	}
+	_, err := c.conn.Write(line)
+	return err
+}
+
+// HealthCheck dials the address if no connection is open. An open one is
+// read from briefly: a collector sends nothing, so only a closed or broken
+// connection returns before the deadline, and it is then dialled again.
+func (c *connWriter) HealthCheck() error {
+	c.Lock()
+	defer c.Unlock()
+	if c.destroyed {
+		return os.ErrClosed
+	}
+	if c.conn != nil {
+		c.conn.SetReadDeadline(time.Now().Add(connProbeTimeout))
+		_, err := c.conn.Read(make([]byte, 1))
+		c.conn.SetReadDeadline(time.Time{})
+		if err == nil || errors.Is(err, os.ErrDeadlineExceeded) {
+			return nil
+		}
+		c.close()
+	}
+	conn, err := net.DialTimeout(c.Net, c.Address, time.Duration(c.DialTimeout)*time.Millisecond)
+	if err != nil {
+		return err
+	}
+	if c.ReconnectOnMsg {
+		return conn.Close()
+	}
+	c.conn = conn
+	return nil
+}
+
+func (c *connWriter) close() {
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+}
+
+func (c *connWriter) Destroy() {
+	c.Lock()
+	c.destroyed = true
+	c.close()
+	c.Unlock()
+}
+
+func (c *connWriter) Flush() {
+}
+
+func init() {
+	Register(AdapterConn, newConnWriter)
+}
@@ -0,0 +1,123 @@
+package wlog
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// collect accepts connections on ln one at a time, until it is closed,
+// and copies what is read from them to out.
+func collect(ln net.Listener, out *syncBuffer) {
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			io.Copy(out, c)
+			c.Close()
+		}
+	}()
+}
+
+func TestConnOrder(t *testing.T) {
+	for _, reconnect := range []bool{false, true} {
+		t.Run(fmt.Sprintf("reconnectOnMsg=%v", reconnect), func(t *testing.T) {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Skip(err)
+			}
+			defer ln.Close()
+			var out syncBuffer
+			collect(ln, &out)
+
+			bl := NewLogger()
+			if err := bl.SetLogger(AdapterConn, fmt.Sprintf(`{"address":%q,"reconnectOnMsg":%t}`, ln.Addr(), reconnect)); err != nil {
+				t.Fatal(err)
+			}
+			var want []string
+			for i := 0; i < 20; i++ {
+				bl.Info("msg %d", i)
+				want = append(want, fmt.Sprintf("msg %d", i))
+			}
+			bl.Close()
+
+			for deadline := time.Now().Add(5 * time.Second); strings.Count(out.String(), "\n") < len(want); time.Sleep(time.Millisecond) {
+				if time.Now().After(deadline) {
+					break
+				}
+			}
+			lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+			if len(lines) != len(want) {
+				t.Fatalf("got %d lines, want %d:\n%s", len(lines), len(want), strings.Join(lines, "\n"))
+			}
+			for i, line := range lines {
+				if !strings.HasSuffix(line, " "+want[i]) {
+					t.Errorf("line %d = %q, want %q", i, line, want[i])
+				}
+			}
+		})
+	}
+}
+
+// TestConnDown logs while the collector is down: each line goes to the
+// fallback with its error reported, without a dial per message, and lines
+// reach the collector again once it is back and the cool-down has passed.
+func TestConnDown(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	bl := NewLogger()
+	var errs []error
+	bl.OnError(func(err error) { errs = append(errs, err) })
+	if err := bl.SetLogger(AdapterConn, fmt.Sprintf(`{"address":%q}`, addr)); err != nil {
+		t.Fatal(err)
+	}
+	defer bl.Close()
+	c := output(t, bl, AdapterConn).(*connWriter)
+	var fallback syncBuffer
+	c.fallback = newLogWriter(&fallback)
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		bl.Info("down %d", i)
+	}
+	if d := time.Since(start); d > 5*connRetryDelay {
+		t.Errorf("5 messages took %s with the collector down", d)
+	}
+	if len(errs) != 5 || strings.Count(fallback.String(), "down ") != 5 {
+		t.Fatalf("errors %v, fallback %q, want 5 of each", errs, fallback.String())
+	}
+	if n := bl.Stats().Errors; n != 5 {
+		t.Errorf("Stats.Errors = %d, want 5", n)
+	}
+
+	ln, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer ln.Close()
+	var out syncBuffer
+	collect(ln, &out)
+	c.Lock()
+	c.retryAt = time.Time{}
+	c.Unlock()
+	bl.Info("up")
+	bl.Close()
+	for deadline := time.Now().Add(5 * time.Second); !strings.Contains(out.String(), "up"); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("collector got %q, want the line logged once it was back", out.String())
+		}
+	}
+	if len(errs) != 5 {
+		t.Errorf("errors %v after the collector came back", errs)
+	}
+}
@@ -0,0 +1,144 @@
+package wlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	consoleOutputStdout = "stdout"
+	consoleOutputStderr = "stderr"
+)
+
+type brush func(string) string
+
+func newBrush(color string) brush {
+	return func(text string) string {
+		return "\033[" + color + "m" + text + "\033[0m"
+	}
+}
+
+var colors = [LevelDebug + 1]brush{
+	newBrush("1;37"), // Emergency     white
+	newBrush("1;36"), // Alert         cyan
+	newBrush("1;35"), // Critical      magenta
+	newBrush("1;31"), // Error         red
+	newBrush("1;33"), // Warning       yellow
+	newBrush("1;32"), // Notice        green
+	newBrush("1;34"), // Informational blue
+	newBrush("0;36"), // Debug         dark cyan
+}
+
+// consoleLogWriter writes to stdout, sending Error and above to stderr unless
+// Output pins every level to a single stream.
+type consoleLogWriter struct {
+	stdout *logWriter
+	stderr *logWriter
+
+	formatter Formatter
+
+	Level  int    `json:"level"`
+	Output string `json:"output"`
+
+	// Colorful colors the level prefix of text lines written to a terminal.
+	// ForceColor colors them whatever the output is. JSON lines are never
+	// colored.
+	Colorful   bool `json:"color"`
+	ForceColor bool `json:"forcecolor"`
+	formatConfig
+
+	colorStdout, colorStderr bool
+}
+
+func newConsoleWriter() Logger {
+	return &consoleLogWriter{
+		stdout:    newLogWriter(os.Stdout),
+		stderr:    newLogWriter(os.Stderr),
+		formatter: TextFormatter{},
+		Level:     LevelTrace,
+	}
+}
+
+func (c *consoleLogWriter) Init(jsonConfig string) error {
+	if len(jsonConfig) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(jsonConfig), c); err != nil {
+		return err
+	}
+	return c.setup()
+}
+
+func (c *consoleLogWriter) setup() error {
+	switch c.Output {
+	case "", consoleOutputStdout, consoleOutputStderr:
+	default:
+		return fmt.Errorf("console: unknown output %q", c.Output)
+	}
+
+	c.colorStdout = c.ForceColor || c.Colorful && isTerminal(c.stdout.writer)
+	c.colorStderr = c.ForceColor || c.Colorful && isTerminal(c.stderr.writer)
+
+	var err error
+	c.formatter, err = c.newFormatter()
+	return err
+}
+
+// isTerminal reports whether w is a character device such as a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+func (c *consoleLogWriter) writer(level int) *logWriter {
+	switch c.Output {
+	case consoleOutputStdout:
+		return c.stdout
+	case consoleOutputStderr:
+		return c.stderr
+	}
+	if level <= LevelError {
+		return c.stderr
+	}
+	return c.stdout
+}
+
+func (c *consoleLogWriter) enabled(level int) bool {
+	return !filtered(level, c.Level)
+}
+
+func (c *consoleLogWriter) WriteMsg(when time.Time, msg string, level int) error {
+	return c.writeRecord(newTextRecord(when, msg, level))
+}
+
+func (c *consoleLogWriter) writeRecord(r *Record) error {
+	if filtered(r.Level, c.Level) {
+		return nil
+	}
+
+	line := formatRecord(c.formatter, r)
+	w := c.writer(r.Level)
+	if _, text := c.formatter.(TextFormatter); text && Level(r.Level).Valid() && r.prefix() != "" && (w == c.stdout && c.colorStdout || w == c.stderr && c.colorStderr) {
+		prefix := r.prefix()
+		line = bytes.Replace(line, []byte(prefix), []byte(colors[r.Level](prefix)), 1)
+	}
+	return w.writeln(line, c.lineSeparator())
+}
+
+func (c *consoleLogWriter) Destroy() {
+}
+
+func (c *consoleLogWriter) Flush() {
+}
+
+func init() {
+	Register(AdapterConsole, newConsoleWriter)
+}
@@ -0,0 +1,46 @@
+package wlog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConsoleColor(t *testing.T) {
+	tests := []struct {
+		config string
+		want   bool
+	}{
+		{`{"forcecolor":true}`, true},
+		{`{"color":true,"forcecolor":true}`, true},
+		{`{"color":true}`, false}, // not a terminal
+		{`{"color":false}`, false},
+		{`{}`, false},
+		{`{"forcecolor":true,"format":"json"}`, false},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		c := newConsoleWriter().(*consoleLogWriter)
+		c.stdout, c.stderr = newLogWriter(&stdout), newLogWriter(&stderr)
+		if err := c.Init(tt.config); err != nil {
+			t.Fatalf("%s: %v", tt.config, err)
+		}
+		c.WriteMsg(time.Now(), "[W] warned of [W] ", LevelWarning)
+		c.WriteMsg(time.Now(), "[E] failed with [E] ", LevelError)
+
+		for _, out := range []struct {
+			s, color string
+		}{
+			{stdout.String(), "\033[1;33m"},
+			{stderr.String(), "\033[1;31m"},
+		} {
+			if got := strings.Contains(out.s, "\033["); got != tt.want {
+				t.Errorf("%s: escape codes in %q = %v, want %v", tt.config, out.s, got, tt.want)
+			}
+			if tt.want && !strings.Contains(out.s, out.color) {
+				t.Errorf("%s: %q lacks color %q", tt.config, out.s, out.color)
+			}
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package wlog
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+type contextKey struct {
+	key   interface{}
+	field string
+}
+
+var (
+	contextKeysMu sync.Mutex
+	contextKeys   atomic.Pointer[[]contextKey]
+	contextFuncs  atomic.Pointer[[]func(context.Context) []interface{}]
+)
+
+// RegisterContextKey makes the *Context methods add the value stored in the
+// context under key as a field named field.
+func RegisterContextKey(key interface{}, field string) {
+	contextKeysMu.Lock()
+	defer contextKeysMu.Unlock()
+	var keys []contextKey
+	if old := contextKeys.Load(); old != nil {
+		keys = append(keys, *old...)
+	}
+	keys = append(keys, contextKey{key: key, field: field})
+	contextKeys.Store(&keys)
+}
+
+// RegisterContextFunc makes the *Context methods add the fields returned
+// by f, as alternating keys and values, for values that need more than a
+// lookup by key, like the trace IDs of a span.
+func RegisterContextFunc(f func(ctx context.Context) []interface{}) {
+	contextKeysMu.Lock()
+	defer contextKeysMu.Unlock()
+	var funcs []func(context.Context) []interface{}
+	if old := contextFuncs.Load(); old != nil {
+		funcs = append(funcs, *old...)
+	}
+	funcs = append(funcs, f)
+	contextFuncs.Store(&funcs)
+}
+
+func contextFields(ctx context.Context, errOut *errorOutput) []Field {
+	if ctx == nil {
+		return nil
+	}
+	var fields []Field
+	if keys := contextKeys.Load(); keys != nil {
+		for _, k := range *keys {
+			if v := ctx.Value(k.key); v != nil {
+				fields = append(fields, Field{Key: k.field, Value: v})
+			}
+		}
+	}
+	if funcs := contextFuncs.Load(); funcs != nil {
+		for _, f := range *funcs {
+			if kv := f(ctx); len(kv) > 0 {
+				fields = append(fields, makeFields(errOut, kv)...)
+			}
+		}
+	}
+	return fields
+}
+
+func (bl *WLogger) logContext(ctx context.Context, level int, format string, v ...interface{}) {
+	if filtered(level, int(bl.level.Load())) {
+		return
+	}
+	bl.writeMsg(1, level, sprintf(format, v...), contextFields(ctx, &bl.errOut))
+}
+
+func (bl *WLogger) EmergencyContext(ctx context.Context, format string, v ...interface{}) {
+	bl.logContext(ctx, LevelEmergency, format, v...)
+}
+
+func (bl *WLogger) AlertContext(ctx context.Context, format string, v ...interface{}) {
+	bl.logContext(ctx, LevelAlert, format, v...)
+}
+
+func (bl *WLogger) CriticalContext(ctx context.Context, format string, v ...interface{}) {
+	bl.logContext(ctx, LevelCritical, format, v...)
+}
+
+func (bl *WLogger) ErrorContext(ctx context.Context, format string, v ...interface{}) {
+	bl.logContext(ctx, LevelError, format, v...)
+}
+
+func (bl *WLogger) WarningContext(ctx context.Context, format string, v ...interface{}) {
+	bl.logContext(ctx, LevelWarning, format, v...)
+}
+
+func (bl *WLogger) NoticeContext(ctx context.Context, format string, v ...interface{}) {
+	bl.logContext(ctx, LevelNotice, format, v...)
+}
+
+func (bl *WLogger) InformationalContext(ctx context.Context, format string, v ...interface{}) {
+	bl.logContext(ctx, LevelInformational, format, v...)
+}
+
+func (bl *WLogger) DebugContext(ctx context.Context, format string, v ...interface{}) {
+	bl.logContext(ctx, LevelDebug, format, v...)
+}
+
+func (bl *WLogger) WarnContext(ctx context.Context, format string, v ...interface{}) {
+	bl.logContext(ctx, LevelWarn, format, v...)
+}
+
+func (bl *WLogger) InfoContext(ctx context.Context, format string, v ...interface{}) {
+	bl.logContext(ctx, LevelInfo, format, v...)
+}
+
+func (bl *WLogger) TraceContext(ctx context.Context, format string, v ...interface{}) {
+	bl.logContext(ctx, LevelTrace, format, v...)
+}
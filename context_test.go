@@ -0,0 +1,55 @@
+package wlog
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+type testCtxKey struct{}
+
+type testSpanKey struct{}
+
+// resetContextRegistry drops the keys and funcs a test registers once it
+// ends, so that runs with -count do not pile them up.
+func resetContextRegistry(t *testing.T) {
+	keys, funcs := contextKeys.Load(), contextFuncs.Load()
+	t.Cleanup(func() {
+		contextKeys.Store(keys)
+		contextFuncs.Store(funcs)
+	})
+}
+
+func TestContextFields(t *testing.T) {
+	resetContextRegistry(t)
+	RegisterContextKey(testCtxKey{}, "trace_id")
+	RegisterContextFunc(func(ctx context.Context) []interface{} {
+		if span, ok := ctx.Value(testSpanKey{}).(string); ok {
+			return []interface{}{"span_id", span}
+		}
+		return nil
+	})
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want string
+	}{
+		{"none", context.Background(), "[I] hello\n"},
+		{"key", context.WithValue(context.Background(), testCtxKey{}, "abc123"), "[I] hello trace_id=abc123\n"},
+		{"key and func", context.WithValue(context.WithValue(context.Background(), testCtxKey{}, "abc123"), testSpanKey{}, "s1"),
+			"[I] hello trace_id=abc123 span_id=s1\n"},
+		{"nil", nil, "[I] hello\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bl := NewLogger()
+			var out syncBuffer
+			bl.AddWriter(&out, LevelDebug)
+			bl.InfoContext(tt.ctx, "hello")
+			if got := out.String(); !strings.HasSuffix(got, tt.want) {
+				t.Errorf("got %q, want suffix %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package wlog
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// deduper collapses runs of identical messages.
+type deduper struct {
+	sync.Mutex
+	window  time.Duration
+	key     string
+	level   int
+	repeats int       // suppressed since the last write or notice
+	since   time.Time // of the last write or notice
+}
+
+// check reports whether the message with key is a repeat to suppress, and
+// how many repeats at which level are due to be reported first.
+func (d *deduper) check(key string, level int, now time.Time) (dup bool, repeats int, repeatLevel int) {
+	d.Lock()
+	defer d.Unlock()
+	if key == d.key {
+		d.repeats++
+		if now.Sub(d.since) < d.window {
+			return true, 0, 0
+		}
+		repeats, d.repeats, d.since = d.repeats, 0, now
+		return true, repeats, d.level
+	}
+	repeats, repeatLevel = d.repeats, d.level
+	d.key, d.level, d.repeats, d.since = key, level, 0, now
+	return false, repeats, repeatLevel
+}
+
+// pending returns the repeats not reported yet and forgets them.
+func (d *deduper) pending() (int, int) {
+	d.Lock()
+	defer d.Unlock()
+	repeats := d.repeats
+	d.key, d.repeats = "", 0
+	return repeats, d.level
+}
+
+// SetDedup collapses runs of identical messages, same level, text and
+// fields, into the first one followed by "last message repeated N times".
+// The notice is written when a different message comes, on Close, and
+// every window while the run lasts. A window of 0 turns it off.
+func (bl *WLogger) SetDedup(window time.Duration) {
+	if window <= 0 {
+		bl.dedup.Store(nil)
+		return
+	}
+	bl.dedup.Store(&deduper{window: window})
+}
+
+// deduped reports whether a message may be written and writes the repeat
+// notice of the run it ends, if any.
+func (bl *WLogger) deduped(level int, msg string, fields []Field, when time.Time) bool {
+	d := bl.dedup.Load()
+	if d == nil {
+		return true
+	}
+	dup, repeats, repeatLevel := d.check(strconv.Itoa(level)+" "+msg+fieldsText(fields), level, when)
+	if repeats > 0 {
+		bl.repeatNotice(repeats, repeatLevel, when)
+	}
+	return !dup
+}
+
+func (bl *WLogger) repeatNotice(repeats int, level int, when time.Time) {
+	bl.dispatch(bl.getRecord(when, level, "last message repeated "+strconv.Itoa(repeats)+" times"))
+}
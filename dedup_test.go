@@ -0,0 +1,59 @@
+package wlog
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDedup(t *testing.T) {
+	tests := []struct {
+		name string
+		log  func(bl *WLogger, clock *fakeClock)
+		want []string
+	}{
+		{"run ended by another message", func(bl *WLogger, clock *fakeClock) {
+			for i := 0; i < 100; i++ {
+				bl.Error("disk full")
+			}
+			bl.Info("recovered")
+		}, []string{"[E] disk full", "[E] last message repeated 99 times", "[I] recovered"}},
+		{"run ended by Close", func(bl *WLogger, clock *fakeClock) {
+			bl.Warn("slow")
+			bl.Warn("slow")
+		}, []string{"[W] slow", "[W] last message repeated 1 times"}},
+		{"level or fields differ", func(bl *WLogger, clock *fakeClock) {
+			bl.Info("x")
+			bl.Warn("x")
+			bl.Infow("x", "k", 1)
+			bl.Infow("x", "k", 2)
+		}, []string{"[I] x", "[W] x", "[I] x k=1", "[I] x k=2"}},
+		{"window", func(bl *WLogger, clock *fakeClock) {
+			bl.Error("loop")
+			bl.Error("loop")
+			clock.advance(time.Minute)
+			bl.Error("loop") // reports the run so far
+			bl.Error("loop")
+		}, []string{"[E] loop", "[E] last message repeated 2 times", "[E] last message repeated 1 times"}},
+		{"no repeats", func(bl *WLogger, clock *fakeClock) {
+			bl.Info("a")
+			bl.Info("b")
+			bl.Info("a")
+		}, []string{"[I] a", "[I] b", "[I] a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &fakeClock{now: time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)}
+			bl := NewLogger()
+			bl.SetClock(clock)
+			var out syncBuffer
+			bl.AddWriter(&out, LevelDebug)
+			bl.SetDedup(time.Minute)
+			tt.log(bl, clock)
+			bl.Close()
+			if got := messages(out.String()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
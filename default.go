@@ -0,0 +1,84 @@
+package wlog
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	defaultLogger atomic.Pointer[WLogger]
+	defaultOnce   sync.Once
+)
+
+// Default returns the logger used by the package-level functions. Unless
+// replaced with SetDefault, it is created on first use, writes to the
+// console and takes its level from WLOG_LEVEL.
+func Default() *WLogger {
+	if l := defaultLogger.Load(); l != nil {
+		return l
+	}
+	defaultOnce.Do(func() {
+		l := NewLogger()
+		l.SetLogger(AdapterConsole)
+		l.ConfigureFromEnv()
+		defaultLogger.CompareAndSwap(nil, l)
+	})
+	return defaultLogger.Load()
+}
+
+// SetDefault replaces the logger used by the package-level functions.
+func SetDefault(l *WLogger) {
+	defaultLogger.Store(l)
+}
+
+func defaultLog(level int, format string, v ...interface{}) {
+	l := Default()
+	if level > int(l.level.Load()) {
+		return
+	}
+	l.writeMsg(1, level, sprintf(format, v...), nil)
+}
+
+func Emergency(format string, v ...interface{}) {
+	defaultLog(LevelEmergency, format, v...)
+}
+
+func Alert(format string, v ...interface{}) {
+	defaultLog(LevelAlert, format, v...)
+}
+
+func Critical(format string, v ...interface{}) {
+	defaultLog(LevelCritical, format, v...)
+}
+
+func Error(format string, v ...interface{}) {
+	defaultLog(LevelError, format, v...)
+}
+
+func Warning(format string, v ...interface{}) {
+	defaultLog(LevelWarning, format, v...)
+}
+
+func Notice(format string, v ...interface{}) {
+	defaultLog(LevelNotice, format, v...)
+}
+
+func Informational(format string, v ...interface{}) {
+	defaultLog(LevelInformational, format, v...)
+}
+
+func Debug(format string, v ...interface{}) {
+	defaultLog(LevelDebug, format, v...)
+}
+
+func Warn(format string, v ...interface{}) {
+	defaultLog(LevelWarn, format, v...)
+}
+
+func Info(format string, v ...interface{}) {
+	defaultLog(LevelInfo, format, v...)
+}
+
+func Trace(format string, v ...interface{}) {
+	defaultLog(LevelTrace, format, v...)
+}
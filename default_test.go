@@ -0,0 +1,57 @@
+package wlog
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDefault(t *testing.T) {
+	var wg sync.WaitGroup
+	got := make([]*WLogger, 8)
+	for i := range got {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			got[i] = Default()
+		}(i)
+	}
+	wg.Wait()
+	for _, l := range got {
+		if l == nil || l != got[0] {
+			t.Fatalf("Default returned %p and %p", l, got[0])
+		}
+	}
+
+	bl := NewLogger()
+	var out syncBuffer
+	bl.AddWriter(&out, LevelDebug)
+	SetDefault(bl)
+	defer SetDefault(got[0])
+
+	tests := []struct {
+		log  func(format string, v ...interface{})
+		want string
+	}{
+		{Emergency, "[M] emergency"},
+		{Alert, "[A] alert"},
+		{Critical, "[C] critical"},
+		{Error, "[E] error"},
+		{Warn, "[W] warn"},
+		{Notice, "[N] notice"},
+		{Info, "[I] info"},
+		{Debug, "[D] debug"},
+	}
+	for _, tt := range tests {
+		tt.log("%s", tt.want[4:])
+		if !strings.Contains(out.String(), tt.want+"\n") {
+			t.Errorf("output lacks %q:\n%s", tt.want, out.String())
+		}
+	}
+
+	bl.SetLevel(LevelError)
+	Info("filtered")
+	if strings.Contains(out.String(), "filtered") {
+		t.Error("package-level Info ignored the level")
+	}
+}
@@ -0,0 +1,105 @@
+package wlog
+
+// Entry is a WLogger bound to a set of key/value fields which are added to
+// every message it writes. It shares outputs, level and async mode with the
+// logger it was derived from.
+type Entry struct {
+	logger *WLogger
+	fields []Field
+}
+
+// With returns an Entry carrying the given alternating keys and values.
+func (bl *WLogger) With(keysAndValues ...interface{}) *Entry {
+	return &Entry{logger: bl, fields: makeFields(&bl.errOut, keysAndValues)}
+}
+
+// With returns a new Entry carrying the fields of e plus the given ones.
+func (e *Entry) With(keysAndValues ...interface{}) *Entry {
+	fields := make([]Field, 0, len(e.fields)+len(keysAndValues)/2)
+	fields = append(fields, e.fields...)
+	fields = append(fields, makeFields(&e.logger.errOut, keysAndValues)...)
+	return &Entry{logger: e.logger, fields: fields}
+}
+
+func (e *Entry) log(level int, format string, v ...interface{}) {
+	if level > int(e.logger.level.Load()) {
+		return
+	}
+	e.logger.writeMsg(1, level, sprintf(format, v...), e.fields)
+}
+
+func (e *Entry) Emergency(format string, v ...interface{}) {
+	e.log(LevelEmergency, format, v...)
+}
+
+func (e *Entry) Alert(format string, v ...interface{}) {
+	e.log(LevelAlert, format, v...)
+}
+
+func (e *Entry) Critical(format string, v ...interface{}) {
+	e.log(LevelCritical, format, v...)
+}
+
+func (e *Entry) Error(format string, v ...interface{}) {
+	e.log(LevelError, format, v...)
+}
+
+func (e *Entry) Warning(format string, v ...interface{}) {
+	e.log(LevelWarning, format, v...)
+}
+
+func (e *Entry) Notice(format string, v ...interface{}) {
+	e.log(LevelNotice, format, v...)
+}
+
+func (e *Entry) Informational(format string, v ...interface{}) {
+	e.log(LevelInformational, format, v...)
+}
+
+func (e *Entry) Debug(format string, v ...interface{}) {
+	e.log(LevelDebug, format, v...)
+}
+
+func (e *Entry) Warn(format string, v ...interface{}) {
+	e.log(LevelWarn, format, v...)
+}
+
+func (e *Entry) Info(format string, v ...interface{}) {
+	e.log(LevelInfo, format, v...)
+}
+
+func (e *Entry) Trace(format string, v ...interface{}) {
+	e.log(LevelTrace, format, v...)
+}
+
+// The *w methods write msg as is, with the alternating keys and values
+// added as fields for this message only. A key without a value is dropped.
+// A Field, such as one from Err, can stand in for a key and its value.
+
+func (bl *WLogger) Debugw(msg string, keysAndValues ...interface{}) {
+	if LevelDebug > int(bl.level.Load()) {
+		return
+	}
+	bl.writeMsg(0, LevelDebug, msg, makeFields(&bl.errOut, keysAndValues))
+}
+
+func (bl *WLogger) Infow(msg string, keysAndValues ...interface{}) {
+	if LevelInformational > int(bl.level.Load()) {
+		return
+	}
+	bl.writeMsg(0, LevelInformational, msg, makeFields(&bl.errOut, keysAndValues))
+}
+
+func (bl *WLogger) Warnw(msg string, keysAndValues ...interface{}) {
+	if LevelWarn > int(bl.level.Load()) {
+		return
+	}
+	bl.writeMsg(0, LevelWarn, msg, makeFields(&bl.errOut, keysAndValues))
+}
+
+func (bl *WLogger) Errorw(msg string, keysAndValues ...interface{}) {
+	if LevelError > int(bl.level.Load()) {
+		return
+	}
+	bl.writeMsg(0, LevelError, msg, makeFields(&bl.errOut, keysAndValues))
+}
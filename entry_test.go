@@ -0,0 +1,40 @@
+package wlog
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSugaredFields(t *testing.T) {
+	log := func(bl *WLogger) {
+		bl.Debugw("debug", "k", "v")
+		bl.Infow("info", "k", "two words", "n", 1)
+		bl.Warnw("warn")
+		bl.Errorw("error", "err", fmt.Errorf("boom"), "ok", false)
+	}
+	tests := []struct {
+		format, want string
+	}{
+		{"text", "12:00:00 [D] debug k=v\n" +
+			"12:00:00 [I] info k=\"two words\" n=1\n" +
+			"12:00:00 [W] warn\n" +
+			"12:00:00 [E] error err=\"boom\" ok=false\n"},
+		{"json", `{"time":"12:00:00","level":"debug","msg":"debug","k":"v"}` + "\n" +
+			`{"time":"12:00:00","level":"info","msg":"info","k":"two words","n":1}` + "\n" +
+			`{"time":"12:00:00","level":"warning","msg":"warn"}` + "\n" +
+			`{"time":"12:00:00","level":"error","msg":"error","err":"boom","ok":false}` + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			bl, dir, _ := newTestFileLogger(t, fmt.Sprintf(`"format":%q,"timeformat":"15:04:05","daily":false`, tt.format))
+			bl.SetClock(&fakeClock{now: time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)})
+			log(bl)
+			bl.Close()
+			if got := readFile(t, filepath.Join(dir, "app.log")); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
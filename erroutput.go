@@ -0,0 +1,53 @@
+package wlog
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+)
+
+// errorOutputSetter is implemented by adapters that report their own
+// errors.
+type errorOutputSetter interface {
+	setErrorOutput(w io.Writer)
+}
+
+// errorOutput is where internal errors are reported, os.Stderr unless
+// replaced while in use.
+type errorOutput struct {
+	p atomic.Pointer[io.Writer]
+}
+
+func (e *errorOutput) set(w io.Writer) {
+	e.p.Store(&w)
+}
+
+func (e *errorOutput) writer() io.Writer {
+	if w := e.p.Load(); w != nil {
+		return *w
+	}
+	return os.Stderr
+}
+
+func (e *errorOutput) printf(format string, v ...interface{}) {
+	fmt.Fprintf(e.writer(), format, v...)
+}
+
+// SetErrorOutput makes the logger and its outputs, including those added
+// later, report internal errors such as failed writes and rotations to w
+// instead of os.Stderr. Errors passed to an OnError function are not
+// written. A nil w restores os.Stderr.
+func (bl *WLogger) SetErrorOutput(w io.Writer) {
+	if w == nil {
+		w = os.Stderr
+	}
+	bl.lock.Lock()
+	defer bl.lock.Unlock()
+	bl.errOut.set(w)
+	for _, l := range bl.loadOutputs() {
+		if s, ok := l.Logger.(errorOutputSetter); ok {
+			s.setErrorOutput(w)
+		}
+	}
+}
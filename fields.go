@@ -0,0 +1,144 @@
+package wlog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Field is a key/value pair attached to a message.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// RecordLogger is implemented by adapters that want each message as a
+// Record, with the fields kept apart, instead of the text passed to
+// WriteMsg. Adapters implementing only WriteMsg get the text as before.
+type RecordLogger interface {
+	WriteRecord(r Record) error
+}
+
+// recordLogger is implemented by adapters that take the Record itself.
+// Other adapters get its text with the fields appended as key=value text.
+type recordLogger interface {
+	writeRecord(r *Record) error
+}
+
+// recordFormatter is implemented by formatters that render a Record,
+// fields included, themselves.
+type recordFormatter interface {
+	formatRecord(r *Record) []byte
+}
+
+// makeFields turns alternating keys and values, and Fields, into Fields,
+// reporting a key without a value to errOut.
+func makeFields(errOut *errorOutput, keysAndValues []interface{}) []Field {
+	fields := make([]Field, 0, len(keysAndValues)/2)
+	for i := 0; i < len(keysAndValues); i++ {
+		if f, ok := keysAndValues[i].(Field); ok {
+			fields = appendField(fields, f)
+			continue
+		}
+		if i+1 == len(keysAndValues) {
+			errOut.printf("wlog: odd number of fields, dropping key %v\n", keysAndValues[i])
+			break
+		}
+		fields = appendField(fields, Field{Key: fmt.Sprint(keysAndValues[i]), Value: keysAndValues[i+1]})
+		i++
+	}
+	return fields
+}
+
+// appendField appends f and, if its value is an error carrying a stack
+// trace, a "stack" field with the trace.
+func appendField(fields []Field, f Field) []Field {
+	fields = append(fields, f)
+	if err, ok := f.Value.(error); ok {
+		if stack := errorStack(err); stack != "" {
+			fields = append(fields, Field{Key: "stack", Value: stack})
+		}
+	}
+	return fields
+}
+
+// Err returns an "error" field for err. Like any Field, it can be passed
+// in place of a key and value to With and the *w methods.
+func Err(err error) Field {
+	return Field{Key: "error", Value: err}
+}
+
+// errorStack returns the stack trace recorded by err or an error it wraps,
+// as github.com/pkg/errors and similar packages do. Their StackTrace
+// methods return different types, so it is looked up by reflection.
+func errorStack(err error) string {
+	for ; err != nil; err = errors.Unwrap(err) {
+		m := reflect.ValueOf(err).MethodByName("StackTrace")
+		if m.IsValid() && m.Type().NumIn() == 0 && m.Type().NumOut() == 1 {
+			return strings.TrimSpace(fmt.Sprintf("%+v", m.Call(nil)[0].Interface()))
+		}
+	}
+	return ""
+}
+
+func formatRecord(f Formatter, r *Record) []byte {
+	if rf, ok := f.(recordFormatter); ok {
+		return rf.formatRecord(r)
+	}
+	return f.Format(r.Time, r.text()+fieldsText(r.Fields), r.Level)
+}
+
+// fieldsText renders fields as " k1=v1 k2=v2", quoting values that would
+// otherwise be ambiguous.
+func fieldsText(fields []Field) string {
+	if len(fields) == 0 {
+		return ""
+	}
+	return string(appendFieldsText(nil, fields))
+}
+
+func appendFieldsText(b []byte, fields []Field) []byte {
+	for _, f := range fields {
+		b = append(b, ' ')
+		b = append(b, f.Key...)
+		b = append(b, '=')
+		v, ok := f.Value.(string)
+		if !ok {
+			v = fmt.Sprint(f.Value)
+		}
+		// Error messages are always quoted, to be found by error=".
+		if _, isErr := f.Value.(error); isErr || v == "" || strings.ContainsAny(v, " \t\n\"=") {
+			b = strconv.AppendQuote(b, v)
+		} else {
+			b = append(b, v...)
+		}
+	}
+	return b
+}
+
+// appendFieldsJSON adds fields as keys to the JSON object in b.
+func appendFieldsJSON(b []byte, fields []Field) []byte {
+	if len(fields) == 0 {
+		return b
+	}
+	b = b[:len(b)-1]
+	for _, f := range fields {
+		v := f.Value
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		value, err := json.Marshal(v)
+		if err != nil {
+			value, _ = json.Marshal(fmt.Sprint(v))
+		}
+		key, _ := json.Marshal(f.Key)
+		b = append(b, ',')
+		b = append(b, key...)
+		b = append(b, ':')
+		b = append(b, value...)
+	}
+	return append(b, '}')
+}
@@ -0,0 +1,136 @@
+package wlog
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// textCollector is an output implementing only WriteMsg.
+type textCollector struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (c *textCollector) Init(config string) error { return nil }
+func (c *textCollector) Destroy()                 {}
+func (c *textCollector) Flush()                   {}
+
+func (c *textCollector) WriteMsg(when time.Time, msg string, level int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lines = append(c.lines, msg)
+	return nil
+}
+
+func init() {
+	Register("testtext", func() Logger { return &textCollector{} })
+}
+
+// TestRecordLogger sends the same messages to an output taking records and
+// to one taking text: the first gets the fields apart, the second rendered
+// into the text.
+func TestRecordLogger(t *testing.T) {
+	bl := NewLogger()
+	for _, adapter := range []string{"testrecords", "testtext"} {
+		if err := bl.SetLogger(adapter); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bl.With("user", "ann", "id", 7).Info("login")
+	bl.Errorw("failed", "err", "timeout")
+
+	records := output(t, bl, "testrecords").(*recordCollector).Records()
+	wantRecords := []struct {
+		msg    string
+		fields []Field
+	}{
+		{"login", []Field{{"user", "ann"}, {"id", 7}}},
+		{"failed", []Field{{"err", "timeout"}}},
+	}
+	if len(records) != len(wantRecords) {
+		t.Fatalf("%d records, want %d", len(records), len(wantRecords))
+	}
+	for i, want := range wantRecords {
+		if records[i].Msg != want.msg || !reflect.DeepEqual(records[i].Fields, want.fields) {
+			t.Errorf("record %d: %q %v, want %q %v", i, records[i].Msg, records[i].Fields, want.msg, want.fields)
+		}
+	}
+
+	text := output(t, bl, "testtext").(*textCollector)
+	text.mu.Lock()
+	defer text.mu.Unlock()
+	wantText := []string{"[I] login user=ann id=7", "[E] failed err=timeout"}
+	if !reflect.DeepEqual(text.lines, wantText) {
+		t.Errorf("text output %q, want %q", text.lines, wantText)
+	}
+}
+
+// stackTrace mimics the StackTrace type of github.com/pkg/errors, which
+// prints the frames with %+v.
+type stackTrace []string
+
+func (s stackTrace) Format(f fmt.State, verb rune) {
+	for _, frame := range s {
+		fmt.Fprintf(f, "\n%s", frame)
+	}
+}
+
+// stackError is an error recording a stack trace.
+type stackError struct{ msg string }
+
+func (e stackError) Error() string { return e.msg }
+
+func (e stackError) StackTrace() stackTrace {
+	return stackTrace{"main.main\n\tmain.go:12", "runtime.main\n\tproc.go:250"}
+}
+
+// wrapped carries the stack of the error it wraps.
+var wrapped = fmt.Errorf("request: %w", stackError{"deep"})
+
+func TestErrorFields(t *testing.T) {
+	const stack = "main.main\n\tmain.go:12\nruntime.main\n\tproc.go:250"
+	plain := errors.New("boom")
+	tests := []struct {
+		name   string
+		log    func(bl *WLogger)
+		fields []Field
+	}{
+		{"plain", func(bl *WLogger) { bl.Errorw("failed", "err", plain) },
+			[]Field{{"err", plain}}},
+		{"Err", func(bl *WLogger) { bl.Errorw("failed", Err(plain)) },
+			[]Field{{"error", plain}}},
+		{"stack", func(bl *WLogger) { bl.Errorw("failed", Err(stackError{"deep"})) },
+			[]Field{{"error", stackError{"deep"}}, {"stack", stack}}},
+		{"wrapped", func(bl *WLogger) { bl.With("err", wrapped).Error("failed") },
+			[]Field{{"err", wrapped}, {"stack", stack}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bl := NewLogger()
+			if err := bl.SetLogger("testrecords"); err != nil {
+				t.Fatal(err)
+			}
+			var out syncBuffer
+			bl.AddWriter(&out, LevelDebug)
+			tt.log(bl)
+
+			records := output(t, bl, "testrecords").(*recordCollector).Records()
+			if len(records) != 1 || !reflect.DeepEqual(records[0].Fields, tt.fields) {
+				t.Fatalf("records %+v, want fields %+v", records, tt.fields)
+			}
+			// In text, the error is quoted and the stack follows it.
+			text := out.String()
+			if err := tt.fields[0].Value.(error); !strings.Contains(text, fmt.Sprintf("%s=%q", tt.fields[0].Key, err.Error())) {
+				t.Errorf("text %q lacks the error", text)
+			}
+			if hasStack := len(tt.fields) > 1; strings.Contains(text, "stack=") != hasStack {
+				t.Errorf("text %q, stack expected %v", text, hasStack)
+			}
+		})
+	}
+}
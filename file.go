@@ -1,68 +1,193 @@
 package wlog
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
-type fileLogWriter struct {
-	sync.RWMutex        //write log order by order and  atomic incr maxLinesCurLines and maxSizeCurSize
-	Filename     string `json:"filename"`
-	fileWriter   *os.File
-	Day          int `json:"day"`
+// FileConfig configures the file adapter. NewFileConfig fills in the
+// defaults; SetFileLogger adds an output with it, and its JSON form is what
+// SetLogger(AdapterFile, config) takes. Format, TimeFormat, UTC and
+// LineSeparator set the line format as for the other outputs.
+type FileConfig struct {
+	// Filename may contain "{level}", as in "app.{level}.log", to write
+	// each level to its own file, "app.error.log" and so on.
+	Filename string `json:"filename"`
 
-	MaxLines         int `json:"maxlines"`
-	maxLinesCurLines int
+	// Day deletes daily rotated files after this many days, 7 if zero. A
+	// negative Day keeps them.
+	Day int `json:"day"`
+
+	// MaxAge deletes rotated files after this many days whether or not
+	// Daily is set, taking precedence over Day.
+	MaxAge int `json:"maxage"`
 
-	MaxSize        int `json:"maxsize"`
-	maxSizeCurSize int
+	MaxLines int `json:"maxlines"`
 
-	Daily         bool `json:"daily"`
-	dailyOpenDate int
-	dailyOpenTime time.Time
+	// SkipLineCount starts the MaxLines count of an existing file at zero
+	// instead of reading the whole file to count its lines when it is
+	// opened. The first rotation then comes late by as many lines.
+	SkipLineCount bool `json:"skiplinecount"`
+
+	MaxSize ByteSize `json:"maxsize"`
+
+	Daily bool `json:"daily"`
 
 	Rotate bool `json:"rotate"`
 
+	// Level is the least severe level written. A FileConfig given to
+	// SetFileLogger with Level 0 writes all levels, like NewFileConfig;
+	// only the JSON form can ask for Emergency alone. Perm, RotatePerm and
+	// DirPerm are octal modes, those of NewFileConfig when empty.
 	Level int    `json:"level"`
 	Perm  string `json:"perm"`
 
 	RotatePerm string `json:"rotateperm"`
 
-	filePath             string `json:"file_path"`
-	fileNameOnly, suffix string
+	// NumberOnly names rotated files app.1.log, app.2.log and so on
+	// without a date, app.1.log being the latest: each rotation renames
+	// the existing ones one number up. MaxBackups limits how many are kept;
+	// Day and MaxAge do not apply to them.
+	NumberOnly bool `json:"numberonly"`
+
+	// SyncAlways syncs the file to disk after every write, so a crash loses
+	// no line already logged. SyncLevel does so for lines at that level
+	// and below only, e.g. 3 for errors. A sync costs a disk flush, which
+	// can bring throughput down from hundreds of thousands of lines per
+	// second to a few hundred on spinning disks.
+	SyncAlways bool `json:"syncalways"`
+	SyncLevel  *int `json:"synclevel,omitempty"`
+
+	// CreateDirs creates the directory of Filename, and any missing
+	// parents, with mode DirPerm when the output starts.
+	CreateDirs bool   `json:"createdirs"`
+	DirPerm    string `json:"dirperm"`
+
+	Compress bool `json:"compress"`
+
+	// MaxBackups keeps at most this many rotated files, deleting the oldest
+	// after each rotation. Zero keeps all; deletion by Day or MaxAge applies
+	// either way.
+	MaxBackups int `json:"maxbackups"`
+
+	// Symlink names a symbolic link kept pointing at the active log file.
+	// With "{level}" in Filename it must contain "{level}" as well, giving
+	// each level file a link of its own.
+	Symlink string `json:"symlink"`
+
+	// Banner is written as the first line every time a file is opened,
+	// including after rotation. "{pid}" is replaced by the process ID.
+	Banner string `json:"banner"`
+
+	// RotateNotice writes "continued from <rotated file>" at the top of the
+	// file opened by a rotation, after Banner.
+	RotateNotice bool `json:"rotatenotice"`
+
+	// BufferKB buffers writes in memory, flushed every second and on
+	// rotation, Flush and Destroy. Zero writes straight to the file.
+	BufferKB int `json:"bufferkb"`
+
+	// ProcessSafe lets several processes write and rotate the same file.
+	// Every write takes an exclusive lock on Filename+".lock" (flock, or
+	// LockFileEx on Windows), checks whether another process rotated the
+	// file and reopens it if so. That costs two system calls and a stat
+	// per write, and writes from all processes are serialized. BufferKB is
+	// ignored, and MaxLines only counts the lines found when the file was
+	// opened plus those written by this process.
+	ProcessSafe bool `json:"processsafe"`
+
+	formatConfig
 }
 
-func newFileWriter() Logger {
-	return &fileLogWriter{
+// NewFileConfig returns the default configuration of the file adapter:
+// daily rotation keeping 7 days of files, all levels, mode 0666, missing
+// directories created with mode 0755.
+func NewFileConfig(filename string) FileConfig {
+	return FileConfig{
+		Filename:   filename,
 		Daily:      true,
 		Day:        7,
 		Rotate:     true,
 		RotatePerm: "0666",
 		Level:      LevelTrace,
 		Perm:       "0666",
+		CreateDirs: true,
+		DirPerm:    "0755",
 	}
 }
 
+type fileLogWriter struct {
+	sync.RWMutex //write log order by order and  atomic incr maxLinesCurLines and maxSizeCurSize
+	FileConfig
+	fileWriter *os.File
+	bufWriter  *bufio.Writer
+	stopCh     chan struct{}
+	loops      sync.WaitGroup // the background loops, waited for by Destroy
+	running    atomic.Int32   // the background loops not yet returned
+
+	maxLinesCurLines int
+	maxSizeCurSize   int
+	dailyOpenDate    int
+	dailyOpenTime    time.Time
+	lockFd           *os.File
+
+	formatter   Formatter
+	clock       clockRef
+	compressing sync.WaitGroup // compressFile calls in flight
+	onRotate    func(oldName, newName string)
+	errOut      errorOutput
+	levels      *levelFiles // set when Filename contains "{level}"
+	destroyed   bool        // set by Destroy, no file is opened after it
+
+	// compressJobs are the rotated files not yet compressed, by name,
+	// guarded by the lock.
+	compressJobs map[string]*compressJob
+	syncFile     func(f *os.File) error // (*os.File).Sync, replaced in tests
+
+	filePath             string
+	fileNameOnly, suffix string
+}
+
+func newFileWriter() Logger {
+	return &fileLogWriter{FileConfig: NewFileConfig(""), syncFile: (*os.File).Sync}
+}
+
 func (w *fileLogWriter) Init(jsonConfig string) error {
-	err := json.Unmarshal([]byte(jsonConfig), w)
-	if err != nil {
+	if err := json.Unmarshal([]byte(jsonConfig), w); err != nil {
 		return err
 	}
+	return w.setup()
+}
 
+func (w *fileLogWriter) setup() error {
 	if len(w.Filename) == 0 {
 		return errors.New("must have filename")
 	}
+	// Clean turns forward slashes into the separator of the platform.
+	w.Filename = filepath.Clean(w.Filename)
+	if w.Symlink != "" {
+		w.Symlink = filepath.Clean(w.Symlink)
+		if w.Symlink == w.Filename {
+			return errors.New("symlink must differ from filename")
+		}
+		if hasLevelTemplate(w.Filename) && !hasLevelTemplate(w.Symlink) {
+			return errors.New("symlink must contain {level} like filename")
+		}
+	}
 	w.suffix = filepath.Ext(w.Filename)
 	w.filePath = filepath.Dir(w.Filename)
 	w.fileNameOnly = strings.TrimSuffix(w.Filename, w.suffix)
@@ -72,9 +197,65 @@ func (w *fileLogWriter) Init(jsonConfig string) error {
 	if w.Day == 0 {
 		w.Day = 7
 	}
+	// Empty modes, as in a FileConfig literal, are those of NewFileConfig.
+	def := NewFileConfig("")
+	if w.Perm == "" {
+		w.Perm = def.Perm
+	}
+	if w.RotatePerm == "" {
+		w.RotatePerm = def.RotatePerm
+	}
+	if w.DirPerm == "" {
+		w.DirPerm = def.DirPerm
+	}
+	var err error
+	w.formatter, err = w.newFormatter()
+	if err != nil {
+		return err
+	}
+	if hasLevelTemplate(w.Filename) {
+		w.levels = &levelFiles{parent: w, files: make(map[int]*fileLogWriter)}
+		return nil
+	}
+	if w.CreateDirs {
+		dirPerm, err := strconv.ParseInt(w.DirPerm, 8, 64)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(w.filePath, os.FileMode(dirPerm)); err != nil {
+			return err
+		}
+	}
+	if w.ProcessSafe {
+		w.BufferKB = 0
+		if w.lockFd, err = os.OpenFile(w.Filename+".lock", os.O_RDWR|os.O_CREATE, 0666); err != nil {
+			return err
+		}
+		if err = lockFile(w.lockFd); err != nil {
+			w.lockFd.Close()
+			return err
+		}
+		defer unlockFile(w.lockFd)
+	}
 
 	err = w.startLogger()
-	return err
+	if err != nil {
+		return err
+	}
+
+	// The background loops run until Destroy closes stopCh, across
+	// rotations.
+	w.stopCh = make(chan struct{})
+	if w.BufferKB > 0 {
+		w.startLoop(w.flushLoop)
+	}
+	if w.Rotate && w.Daily {
+		w.startLoop(w.dailyRotate)
+	}
+	if w.Rotate && w.maxAge() > 0 {
+		w.startLoop(w.taskDeleteLog)
+	}
+	return nil
 }
 
 func (w *fileLogWriter) startLogger() error {
@@ -84,50 +265,249 @@ func (w *fileLogWriter) startLogger() error {
 	}
 
 	if w.fileWriter != nil {
+		w.flushBuffer()
 		w.fileWriter.Close()
 	}
 
 	w.fileWriter = file
+	w.updateSymlink()
+	if w.BufferKB > 0 {
+		if w.bufWriter == nil {
+			w.bufWriter = bufio.NewWriterSize(file, w.BufferKB*1024)
+		} else {
+			w.bufWriter.Reset(file)
+		}
+	}
+
+	if err := w.initFd(); err != nil {
+		return err
+	}
+	return w.writeBanner()
+}
+
+// writeBanner writes Banner to the newly opened file, counting it like any
+// other line.
+func (w *fileLogWriter) writeBanner() error {
+	if w.Banner == "" {
+		return nil
+	}
+	line := strings.ReplaceAll(w.Banner, "{pid}", strconv.Itoa(os.Getpid())) + w.lineSeparator()
+	if _, err := w.write([]byte(line)); err != nil {
+		return err
+	}
+	w.maxLinesCurLines++
+	w.maxSizeCurSize += len(line)
+	return nil
+}
 
-	return w.initFd()
+// Reopen closes the log file and opens Filename again, creating it if it
+// was moved away.
+func (w *fileLogWriter) Reopen() error {
+	if w.levels != nil {
+		return w.levels.each((*fileLogWriter).Reopen)
+	}
+	w.Lock()
+	defer w.Unlock()
+	if w.destroyed {
+		return os.ErrClosed
+	}
+	return w.startLogger()
 }
 
+// HealthCheck flushes the buffer and makes sure the file is still in place
+// and can be opened for writing. Files of a "{level}" output are checked
+// once they have been opened.
+func (w *fileLogWriter) HealthCheck() error {
+	if w.levels != nil {
+		return w.levels.each((*fileLogWriter).HealthCheck)
+	}
+	w.Lock()
+	defer w.Unlock()
+	if w.fileWriter == nil {
+		return errors.New("file not open")
+	}
+	if w.bufWriter != nil {
+		if err := w.bufWriter.Flush(); err != nil {
+			return err
+		}
+	}
+	open, err := w.fileWriter.Stat()
+	if err != nil {
+		return err
+	}
+	cur, err := os.Stat(w.Filename)
+	if err != nil {
+		return err
+	}
+	// With ProcessSafe another process may have rotated the file, which
+	// is reopened on the next write.
+	if !w.ProcessSafe && !os.SameFile(open, cur) {
+		return fmt.Errorf("%s was replaced", w.Filename)
+	}
+	f, err := os.OpenFile(w.Filename, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// rotate rotates the file regardless of MaxLines, MaxSize and Daily.
+func (w *fileLogWriter) rotate() error {
+	if w.levels != nil {
+		return w.levels.each((*fileLogWriter).rotate)
+	}
+	w.Lock()
+	defer w.Unlock()
+	if w.destroyed {
+		return os.ErrClosed
+	}
+	if w.ProcessSafe {
+		if err := w.lockShared(); err != nil {
+			return err
+		}
+		defer unlockFile(w.lockFd)
+	}
+	return w.doRotate(w.now())
+}
+
+// needRotate reports whether the file must be rotated before size more
+// bytes are written to it on day. Rotating ahead of the write keeps files
+// within MaxSize, except for a single line larger than MaxSize, which goes
+// to a file of its own.
 func (w *fileLogWriter) needRotate(size, day int) bool {
 	return (w.MaxLines > 0 && w.maxLinesCurLines >= w.MaxLines) ||
-		(w.MaxSize > 0 && w.maxSizeCurSize >= w.MaxSize) || (w.Daily && day != w.dailyOpenDate && w.maxLinesCurLines > 0)
+		(w.MaxSize > 0 && w.maxSizeCurSize > 0 && w.maxSizeCurSize+size > int(w.MaxSize)) ||
+		(w.Daily && day != w.dailyOpenDate && w.maxLinesCurLines > 0)
+}
+
+func (w *fileLogWriter) enabled(level int) bool {
+	return !filtered(level, w.Level)
 }
 
 func (w *fileLogWriter) WriteMsg(when time.Time, msg string, level int) error {
-	if level > w.Level {
+	return w.writeRecord(newTextRecord(when, msg, level))
+}
+
+func (w *fileLogWriter) writeRecord(r *Record) error {
+	if filtered(r.Level, w.Level) {
 		return nil
 	}
+	if w.levels != nil {
+		lw, err := w.levels.get(r.Level)
+		if err != nil {
+			return err
+		}
+		return lw.writeRecord(r)
+	}
 
-	h, day := formatTimeHeader(when)
-	msg = h + msg + "\n"
-	if w.Rotate {
-		w.RLock()
-		if w.needRotate(len(msg), day) {
-			w.RUnlock()
-			w.Lock()
-			if w.needRotate(len(msg), day) {
-				if err := w.doRotate(when); err != nil {
-					fmt.Fprintf(os.Stderr, "FileLogWriter(%q): %s\n", w.Filename, err)
-				}
+	line := append(formatRecord(w.formatter, r), w.lineSeparator()...)
+	return w.writeLines(line, 1, r.Time, w.needSync(r.Level))
+}
+
+// writeBatch writes all accepted messages with a single write. Rotation is
+// only checked once per batch, so a batch may run past MaxLines or MaxSize.
+func (w *fileLogWriter) writeBatch(rs []*Record) error {
+	if w.levels != nil {
+		var firstErr error
+		for _, r := range rs {
+			if err := w.writeRecord(r); err != nil && firstErr == nil {
+				firstErr = err
 			}
-			w.Unlock()
-		} else {
-			w.RUnlock()
 		}
+		return firstErr
+	}
+	var buf []byte
+	lines := 0
+	doSync := false
+	for _, r := range rs {
+		if filtered(r.Level, w.Level) {
+			continue
+		}
+		buf = append(buf, formatRecord(w.formatter, r)...)
+		buf = append(buf, w.lineSeparator()...)
+		lines++
+		doSync = doSync || w.needSync(r.Level)
 	}
+	if lines == 0 {
+		return nil
+	}
+	return w.writeLines(buf, lines, rs[0].Time, doSync)
+}
 
+// writeLines writes b, holding lines lines, rotating first if needed, and
+// syncs the file to disk if doSync is set.
+func (w *fileLogWriter) writeLines(b []byte, lines int, when time.Time, doSync bool) error {
+	// The rotation check and the write share one lock, so the counters
+	// cannot change in between.
 	w.Lock()
-	_, err := w.fileWriter.Write([]byte(msg))
-	if err == nil {
-		w.maxLinesCurLines++
-		w.maxSizeCurSize += len(msg)
+	defer w.Unlock()
+	// A write racing with DelNamedLogger or Close can arrive after
+	// Destroy; it must not open the file again.
+	if w.destroyed {
+		return os.ErrClosed
 	}
-	w.Unlock()
-	return err
+	if w.ProcessSafe {
+		if err := w.lockShared(); err != nil {
+			return err
+		}
+		defer unlockFile(w.lockFd)
+	}
+
+	if w.Rotate && w.needRotate(len(b), inZone(when, w.UTC).Day()) {
+		if err := w.doRotate(when); err != nil {
+			w.errOut.printf("FileLogWriter(%q): %s\n", w.Filename, err)
+		}
+	}
+	if _, err := w.write(b); err != nil {
+		return err
+	}
+	w.maxLinesCurLines += lines
+	w.maxSizeCurSize += len(b)
+	if !doSync {
+		return nil
+	}
+	if w.bufWriter != nil {
+		if err := w.bufWriter.Flush(); err != nil {
+			return err
+		}
+	}
+	// Devices and pipes such as /dev/stdout cannot be synced.
+	if err := w.syncFile(w.fileWriter); !errors.Is(err, syscall.EINVAL) {
+		return err
+	}
+	return nil
+}
+
+// needSync reports whether a line at level must be synced to disk.
+func (w *fileLogWriter) needSync(level int) bool {
+	return w.SyncAlways || w.SyncLevel != nil && level <= *w.SyncLevel
+}
+
+// lockShared takes the lock file and catches up with what other processes
+// did since: the file is reopened if one of them rotated it, and its size
+// includes their writes.
+func (w *fileLogWriter) lockShared() error {
+	if w.lockFd == nil {
+		return os.ErrClosed
+	}
+	if err := lockFile(w.lockFd); err != nil {
+		return err
+	}
+	if w.fileWriter == nil {
+		return nil
+	}
+	cur, err := w.fileWriter.Stat()
+	if err != nil {
+		return nil
+	}
+	if fi, err := os.Stat(w.Filename); err != nil || !os.SameFile(fi, cur) {
+		if err := w.startLogger(); err != nil {
+			w.errOut.printf("FileLogWriter(%q): %s\n", w.Filename, err)
+		}
+		return nil
+	}
+	w.maxSizeCurSize = int(cur.Size())
+	return nil
 }
 
 func (w *fileLogWriter) createLogFile() (*os.File, error) {
@@ -150,15 +530,11 @@ func (w *fileLogWriter) initFd() error {
 	}
 
 	w.maxSizeCurSize = int(fInfo.Size())
-	w.dailyOpenTime = time.Now().Local()
+	w.dailyOpenTime = w.now()
 	w.dailyOpenDate = w.dailyOpenTime.Day()
 	w.maxLinesCurLines = 0
-	if w.Daily {
-		go w.dailyRotate(w.dailyOpenTime)
-		go w.taskDeleteLog()
-	}
 
-	if fInfo.Size() > 0 && w.MaxLines > 0 {
+	if fInfo.Size() > 0 && w.MaxLines > 0 && !w.SkipLineCount {
 		count, err := w.lines()
 		if err != nil {
 			return err
@@ -168,19 +544,96 @@ func (w *fileLogWriter) initFd() error {
 	return nil
 }
 
-func (w *fileLogWriter) dailyRotate(openTime time.Time) {
-	now := time.Now().Local()
-	y, m, d := openTime.Add(24 * time.Hour).Date()
-	nextDay := time.Date(y, m, d, 0, 0, 0, 0, openTime.Location())
-	tm := time.NewTimer(time.Duration(nextDay.UnixNano() - openTime.UnixNano() + 60))
-	<-tm.C
+// nextMidnight returns the start of the day after t in t's location. Days
+// are not always 24 hours long, so it must not be computed by adding one.
+func nextMidnight(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, t.Location())
+}
+
+// untilMidnight returns the time from now to the next midnight.
+func untilMidnight(now time.Time) time.Duration {
+	return nextMidnight(now).Sub(now)
+}
+
+func (w *fileLogWriter) setErrorOutput(out io.Writer) {
+	w.errOut.set(out)
+	if w.levels != nil {
+		w.levels.each(func(lw *fileLogWriter) error {
+			lw.setErrorOutput(out)
+			return nil
+		})
+	}
+}
+
+func (w *fileLogWriter) setClock(c Clock) {
+	w.clock.set(c)
+	if w.levels != nil {
+		w.levels.each(func(lw *fileLogWriter) error {
+			lw.setClock(c)
+			return nil
+		})
+	}
+}
+
+func (w *fileLogWriter) setOnRotate(f func(oldName, newName string)) {
 	w.Lock()
-	if w.needRotate(0, now.Day()) {
-		if err := w.doRotate(now); err != nil {
-			fmt.Fprintf(os.Stderr, "FileLogWriter(%q): %s\n", w.Filename, err)
+	w.onRotate = f
+	w.Unlock()
+	if w.levels != nil {
+		w.levels.each(func(lw *fileLogWriter) error {
+			lw.setOnRotate(f)
+			return nil
+		})
+	}
+}
+
+// now returns the current time in the writer's zone.
+func (w *fileLogWriter) now() time.Time {
+	return inZone(w.clock.now(), w.UTC)
+}
+
+// dailyRotate rotates the file at every midnight, unless a write got there
+// first.
+func (w *fileLogWriter) dailyRotate(stop chan struct{}) {
+	for {
+		changed := w.clock.onChange()
+		midnight, stopTimer := w.clock.after(untilMidnight(w.now()))
+		select {
+		case <-midnight:
+		case <-changed:
+			stopTimer()
+			continue
+		case <-stop:
+			stopTimer()
+			return
 		}
+		now := w.now()
+		w.Lock()
+		if err := w.rotateAt(now); err != nil {
+			w.errOut.printf("FileLogWriter(%q): %s\n", w.Filename, err)
+		}
+		w.Unlock()
 	}
-	w.Unlock()
+}
+
+// rotateAt does the daily rotation due at now, if no write did it yet and
+// Destroy has not run: when the timer and stopCh are ready together, the
+// loop may pick the timer after Destroy.
+func (w *fileLogWriter) rotateAt(now time.Time) error {
+	if w.destroyed {
+		return nil
+	}
+	if w.ProcessSafe {
+		if err := w.lockShared(); err != nil {
+			return err
+		}
+		defer unlockFile(w.lockFd)
+	}
+	if !w.needRotate(0, now.Day()) {
+		return nil
+	}
+	return w.doRotate(now)
 }
 
 func (w *fileLogWriter) lines() (int, error) {
@@ -192,7 +645,7 @@ func (w *fileLogWriter) lines() (int, error) {
 
 	buf := make([]byte, 32768) //32k
 	count := 0
-	lineSep := []byte{'\n'}
+	lineSep := []byte(w.lineSeparator())
 
 	for {
 		c, err := fd.Read(buf)
@@ -211,10 +664,8 @@ func (w *fileLogWriter) lines() (int, error) {
 }
 
 func (w *fileLogWriter) doRotate(logTime time.Time) error {
-	// file exists
-	// Find the next available number
-	num := 1
-	fName := ""
+	logTime = inZone(logTime, w.UTC)
+	var fName string
 	rotatePerm, err := strconv.ParseInt(w.RotatePerm, 8, 64)
 	if err != nil {
 		return err
@@ -225,27 +676,23 @@ func (w *fileLogWriter) doRotate(logTime time.Time) error {
 		goto RESTART_LOGGER
 	}
 
-	if w.MaxLines > 0 || w.MaxSize > 0 {
-		for ; err == nil && num <= 999; num++ {
-			fName = w.fileNameOnly + fmt.Sprintf(".%s.%03d%s", logTime.Format("2006-01-02"), num, w.suffix)
-			_, err = os.Lstat(fName)
-		}
+	if w.NumberOnly {
+		fName, err = w.shiftNumbered()
+	} else if w.MaxLines > 0 || w.MaxSize > 0 {
+		fName, err = w.nextRotatedName(logTime, false)
 	} else {
-		fName = fmt.Sprintf("%s.%s%s", w.fileNameOnly, w.dailyOpenTime.Format("2006-01-02"), w.suffix)
-		_, err = os.Lstat(fName)
-		for ; err == nil && num <= 999; num++ {
-			fName = w.fileNameOnly + fmt.Sprintf(".%s.%03d%s", w.dailyOpenTime.Format("2006-01-02"), num, w.suffix)
-			_, err = os.Lstat(fName)
-		}
+		fName, err = w.nextRotatedName(w.dailyOpenTime, true)
 	}
-
-	// return error if the last file checked still existed
-	if err == nil {
-		return fmt.Errorf("Rotate: Cannot find free log number to rename %s:%s\n", w.Filename, err.Error())
+	if err != nil {
+		return fmt.Errorf("Rotate: %s\n", err)
 	}
 
 	// close fileWriter before rename
-	w.fileWriter.Close()
+	w.flushBuffer()
+	if w.fileWriter != nil {
+		w.fileWriter.Close()
+		w.fileWriter = nil
+	}
 
 	// Rename the file to its new found name
 	// even if occurs error,we MUST guarantee to  restart new logger
@@ -254,6 +701,18 @@ func (w *fileLogWriter) doRotate(logTime time.Time) error {
 		goto RESTART_LOGGER
 	}
 	err = os.Chmod(fName, os.FileMode(rotatePerm))
+	if err == nil && w.MaxBackups > 0 && !w.NumberOnly {
+		w.deleteExtraBackups()
+	}
+	if err == nil && w.Compress {
+		job := &compressJob{name: fName}
+		if w.compressJobs == nil {
+			w.compressJobs = make(map[string]*compressJob)
+		}
+		w.compressJobs[fName] = job
+		w.compressing.Add(1)
+		go w.compressFile(job, os.FileMode(rotatePerm))
+	}
 
 RESTART_LOGGER:
 	startLoggerErr := w.startLogger()
@@ -264,41 +723,556 @@ RESTART_LOGGER:
 	if err != nil {
 		return fmt.Errorf("Rotate: %s\n", err)
 	}
+	if fName == "" {
+		return nil
+	}
+	if w.RotateNotice {
+		line := "continued from " + filepath.Base(fName) + w.lineSeparator()
+		if _, err := w.write([]byte(line)); err != nil {
+			return err
+		}
+		w.maxLinesCurLines++
+		w.maxSizeCurSize += len(line)
+	}
+	if w.onRotate != nil {
+		w.onRotate(w.Filename, fName)
+	}
 	return nil
 }
 
+// nextRotatedName returns the name to rotate the log file to for day. The
+// number follows the highest one already on disk for that day, so a
+// restarted process neither overwrites nor reuses a name. With plain set,
+// the unnumbered "<name>.2006-01-02<suffix>" is used while it is free.
+func (w *fileLogWriter) nextRotatedName(day time.Time, plain bool) (string, error) {
+	date := day.Format("2006-01-02")
+	if plain {
+		fName := w.rotatedName(date, 0)
+		if lstatRotated(fName) != nil {
+			return fName, nil
+		}
+	}
+
+	files, err := w.rotatedFiles()
+	if err != nil {
+		return "", err
+	}
+	num := 1
+	for _, f := range files {
+		if f.date.Format("2006-01-02") == date && f.num >= num {
+			num = f.num + 1
+		}
+	}
+	if num > 999 {
+		return "", fmt.Errorf("cannot find free log number to rename %s", w.Filename)
+	}
+	return w.rotatedName(date, num), nil
+}
+
+// shiftNumbered makes room for a NumberOnly rotation, renaming app.1.log to
+// app.2.log and so on and removing those past MaxBackups, and returns the
+// name for the file being rotated, app.1.log.
+func (w *fileLogWriter) shiftNumbered() (string, error) {
+	name := func(n int) string {
+		return w.fileNameOnly + "." + strconv.Itoa(n) + w.suffix
+	}
+	last := 0
+	for lstatRotated(name(last+1)) == nil {
+		last++
+	}
+	for ; w.MaxBackups > 0 && last >= w.MaxBackups; last-- {
+		w.dropCompressJob(name(last))
+		os.Remove(name(last))
+		os.Remove(name(last) + ".gz")
+	}
+	for n := last; n >= 1; n-- {
+		for _, ext := range []string{"", ".gz"} {
+			if err := os.Rename(name(n)+ext, name(n+1)+ext); err != nil && !os.IsNotExist(err) {
+				return "", err
+			}
+		}
+		w.moveCompressJob(name(n), name(n+1))
+	}
+	return name(1), nil
+}
+
+// rotatedName returns the path of the rotated file for date, numbered
+// unless num is 0.
+func (w *fileLogWriter) rotatedName(date string, num int) string {
+	name := filepath.Base(w.fileNameOnly) + "." + date
+	if num > 0 {
+		name += fmt.Sprintf(".%03d", num)
+	}
+	return filepath.Join(w.filePath, name+w.suffix)
+}
+
+// updateSymlink atomically points Symlink at the log file. Failures, e.g.
+// on platforms without symlinks, are reported and otherwise ignored.
+func (w *fileLogWriter) updateSymlink() {
+	if w.Symlink == "" {
+		return
+	}
+	target, err := filepath.Abs(w.Filename)
+	if err == nil {
+		var dir string
+		if dir, err = filepath.Abs(filepath.Dir(w.Symlink)); err == nil {
+			target, err = filepath.Rel(dir, target)
+		}
+	}
+	if err != nil {
+		target = w.Filename
+	}
+
+	tmp := w.Symlink + ".tmp"
+	os.Remove(tmp)
+	if err = os.Symlink(target, tmp); err == nil {
+		if err = os.Rename(tmp, w.Symlink); err != nil {
+			os.Remove(tmp)
+		}
+	}
+	if err != nil {
+		w.errOut.printf("FileLogWriter(%q): symlink: %s\n", w.Filename, err)
+	}
+}
+
+// lstatRotated returns nil if name or its compressed form exists.
+func lstatRotated(name string) error {
+	_, err := os.Lstat(name)
+	if err != nil {
+		_, err = os.Lstat(name + ".gz")
+	}
+	return err
+}
+
+// compressSlots bounds how many rotated files are gzipped at once.
+var compressSlots = make(chan struct{}, 2)
+
+// compressJob is a rotated file waiting to be compressed or being
+// compressed. name follows the file when a NumberOnly rotation renames it
+// and is cleared when the file is removed, both under the writer's lock.
+type compressJob struct {
+	name string
+}
+
+// moveCompressJob makes the job of the file from follow its rename to to.
+// The caller must hold the lock.
+func (w *fileLogWriter) moveCompressJob(from, to string) {
+	if job, ok := w.compressJobs[from]; ok {
+		delete(w.compressJobs, from)
+		job.name = to
+		w.compressJobs[to] = job
+	}
+}
+
+// dropCompressJob cancels the job of name, which is being removed. The
+// caller must hold the lock.
+func (w *fileLogWriter) dropCompressJob(name string) {
+	if job, ok := w.compressJobs[name]; ok {
+		delete(w.compressJobs, name)
+		job.name = ""
+	}
+}
+
+// compressFile gzips the file of job to name.gz and removes it once that
+// succeeded. Rotations go on meanwhile: the files are opened under the
+// lock, so a removal of the rotated file either cancels the job first or
+// unlinks both files, and a rename is followed through the open files.
+func (w *fileLogWriter) compressFile(job *compressJob, perm os.FileMode) {
+	defer w.compressing.Done()
+	compressSlots <- struct{}{}
+	defer func() { <-compressSlots }()
+
+	w.Lock()
+	if job.name == "" {
+		w.Unlock()
+		return
+	}
+	src, dst, err := openGzip(job.name, perm)
+	w.Unlock()
+	if err == nil {
+		err = gzipFile(dst, src)
+	}
+
+	w.Lock()
+	defer w.Unlock()
+	name := job.name
+	if name == "" {
+		return
+	}
+	delete(w.compressJobs, name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// Removed meanwhile, e.g. by hand.
+			return
+		}
+		os.Remove(name + ".gz")
+		w.errOut.printf("FileLogWriter(%q): compress %s: %s\n", w.Filename, name, err)
+		return
+	}
+	os.Remove(name)
+}
+
+// openGzip opens name and creates name.gz for gzipFile.
+func openGzip(name string, perm os.FileMode) (src, dst *os.File, err error) {
+	src, err = os.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	dst, err = os.OpenFile(name+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		src.Close()
+		return nil, nil, err
+	}
+	return src, dst, nil
+}
+
+// gzipFile compresses src into dst and closes both.
+func gzipFile(dst, src *os.File) error {
+	defer src.Close()
+	gz := gzip.NewWriter(dst)
+	_, err := io.Copy(gz, src)
+	if err == nil {
+		err = gz.Close()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (w *fileLogWriter) write(b []byte) (int, error) {
+	if w.fileWriter == nil {
+		// A failed rotation left no file open; try again for every write
+		// until the file can be created.
+		if err := w.startLogger(); err != nil {
+			return 0, err
+		}
+	}
+	if w.bufWriter != nil {
+		// Flush first rather than let bufio split the line across two
+		// writes; a line longer than the buffer is written directly.
+		if len(b) > w.bufWriter.Available() && w.bufWriter.Buffered() > 0 {
+			if err := w.bufWriter.Flush(); err != nil {
+				return 0, err
+			}
+		}
+		return w.bufWriter.Write(b)
+	}
+	return w.fileWriter.Write(b)
+}
+
+// flushBuffer writes out buffered lines. The caller must hold the lock.
+func (w *fileLogWriter) flushBuffer() {
+	if w.bufWriter == nil {
+		return
+	}
+	if err := w.bufWriter.Flush(); err != nil {
+		w.errOut.printf("FileLogWriter(%q): %s\n", w.Filename, err)
+	}
+}
+
+func (w *fileLogWriter) flushLoop(stop chan struct{}) {
+	t := time.NewTicker(time.Second)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			w.Lock()
+			w.flushBuffer()
+			w.Unlock()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Destroy and Flush wait for rotated files still being compressed, so no
+// half-written .gz is left behind on exit.
 func (w *fileLogWriter) Destroy() {
-	w.fileWriter.Close()
+	if w.levels != nil {
+		w.levels.destroy()
+		return
+	}
+	w.Lock()
+	w.destroyed = true
+	if w.stopCh != nil {
+		close(w.stopCh)
+		w.stopCh = nil
+	}
+	w.flushBuffer()
+	if w.fileWriter != nil {
+		w.fileWriter.Close()
+		w.fileWriter = nil
+	}
+	if w.lockFd != nil {
+		w.lockFd.Close()
+		w.lockFd = nil
+	}
+	w.Unlock()
+	w.loops.Wait()
+	w.compressing.Wait()
+}
+
+// startLoop runs loop until Destroy closes stopCh.
+func (w *fileLogWriter) startLoop(loop func(stop chan struct{})) {
+	w.loops.Add(1)
+	w.running.Add(1)
+	go func(stop chan struct{}) {
+		defer w.loops.Done()
+		defer w.running.Add(-1)
+		loop(stop)
+	}(w.stopCh)
 }
 
 func (w *fileLogWriter) Flush() {
-	w.fileWriter.Sync()
+	if err := w.flushErr(); err != nil {
+		w.errOut.printf("FileLogWriter(%q): %s\n", w.Filename, err)
+	}
+}
+
+// flushErr writes out the buffer and syncs the file, returning the first
+// error, such as a full disk.
+func (w *fileLogWriter) flushErr() error {
+	if w.levels != nil {
+		return w.levels.each((*fileLogWriter).flushErr)
+	}
+	w.Lock()
+	var err error
+	if w.bufWriter != nil {
+		err = w.bufWriter.Flush()
+	}
+	if w.fileWriter != nil {
+		// Devices and pipes such as /dev/stdout cannot be synced.
+		if serr := w.syncFile(w.fileWriter); err == nil && !errors.Is(serr, syscall.EINVAL) {
+			err = serr
+		}
+	}
+	w.Unlock()
+	w.compressing.Wait()
+	return err
+}
+
+// SetFileLogger adds a file output configured by cfg, as SetLogger with
+// AdapterFile and cfg in JSON would, without going through JSON. Build cfg
+// with NewFileConfig: in a FileConfig literal a zero Level means
+// LevelEmergency, as "level":0 does in JSON.
+func (bl *WLogger) SetFileLogger(cfg FileConfig) error {
+	return bl.addLogger(AdapterFile, AdapterFile, cfg)
+}
+
+// OnRotate sets a function called each time a file output, including one
+// added later, renames its active file oldName to newName on rotation. It
+// runs with the output locked, so it must not log to it.
+func (bl *WLogger) OnRotate(f func(oldName, newName string)) {
+	bl.lock.Lock()
+	defer bl.lock.Unlock()
+	bl.onRotate = f
+	for _, l := range bl.loadOutputs() {
+		if w, ok := l.Logger.(*fileLogWriter); ok {
+			w.setOnRotate(f)
+		}
+	}
 }
 
-func (w *fileLogWriter) taskDeleteLog() {
-	day := strconv.Itoa(w.Day)
+// FileStats describes the active file of a file output.
+type FileStats struct {
+	Filename string
+	Size     int // bytes in the file, counting buffered ones
+	Lines    int // lines written since the file was opened
 
-	d := time.Now()
-	date := time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, time.Local)
-	diff := (date.Unix() + 86400) - d.Unix()
-	t := time.NewTimer(time.Duration(diff) * time.Second)
+	// Files holds, for an output whose Filename contains "{level}", the
+	// stats of each level's file opened so far, by level name. Filename is
+	// then the template, and Size and Lines are the sums over Files.
+	Files map[string]FileStats
+}
 
-	goos := runtime.GOOS
+// FileStats returns the active file of the file output added under name,
+// AdapterFile unless it was added with SetNamedLogger. It reports false if
+// there is no such output.
+func (bl *WLogger) FileStats(name string) (FileStats, bool) {
+	bl.lock.Lock()
+	defer bl.lock.Unlock()
+	for _, l := range bl.loadOutputs() {
+		if w, ok := l.Logger.(*fileLogWriter); ok && l.name == name {
+			return w.stats(), true
+		}
+	}
+	return FileStats{}, false
+}
 
+func (w *fileLogWriter) stats() FileStats {
+	if w.levels != nil {
+		return w.levels.stats(w.Filename)
+	}
+	w.RLock()
+	defer w.RUnlock()
+	return FileStats{
+		Filename: w.Filename,
+		Size:     w.maxSizeCurSize,
+		Lines:    w.maxLinesCurLines,
+	}
+}
+
+func (w *fileLogWriter) taskDeleteLog(stop chan struct{}) {
 	for {
-		<-t.C
+		changed := w.clock.onChange()
+		midnight, stopTimer := w.clock.after(untilMidnight(w.now()))
+		select {
+		case <-midnight:
+		case <-changed:
+			stopTimer()
+			continue
+		case <-stop:
+			stopTimer()
+			return
+		}
+		w.deleteOldLog()
+	}
+}
 
-		if goos == "windows" {
-			execArr := []string{"/c", "forfiles", "-p", w.filePath, "-s", "-m", "*", "-d", "-" + day,
-				"-c", "cmd /c del /q /f @path"}
+// maxAge returns the age in days after which rotated files are deleted, or
+// zero to keep them.
+func (w *fileLogWriter) maxAge() int {
+	if w.MaxAge > 0 {
+		return w.MaxAge
+	}
+	if w.Daily && w.Day > 0 {
+		return w.Day
+	}
+	return 0
+}
 
-			exec.Command("cmd", execArr...).CombinedOutput()
-		} else {
-			execName := "find " + w.filePath + `/ -mtime +` + day + ` -name "*" -exec rm -rf {} \;`
+// deleteOldLog removes rotated files of this writer whose rotation date is
+// more than maxAge days ago. Files not produced by doRotate are left alone.
+func (w *fileLogWriter) deleteOldLog() {
+	w.Lock()
+	defer w.Unlock()
+	files, err := w.rotatedFiles()
+	if err != nil {
+		w.errOut.printf("FileLogWriter(%q): %s\n", w.Filename, err)
+		return
+	}
 
-			exec.Command(execName).CombinedOutput()
+	now := w.now()
+	cutoff := time.Date(now.Year(), now.Month(), now.Day()-w.maxAge(), 0, 0, 0, 0, now.Location())
+	for _, f := range files {
+		if f.date.Before(cutoff) {
+			w.removeRotated(f)
 		}
+	}
+}
+
+// deleteExtraBackups removes the oldest rotated files beyond MaxBackups.
+// The caller must hold the lock.
+func (w *fileLogWriter) deleteExtraBackups() {
+	files, err := w.rotatedFiles()
+	if err != nil {
+		w.errOut.printf("FileLogWriter(%q): %s\n", w.Filename, err)
+		return
+	}
+	if len(files) <= w.MaxBackups {
+		return
+	}
 
-		t.Reset(24 * time.Hour)
+	// Numbers freed by earlier deletions get reused, so within a day order
+	// by modification time rather than by number.
+	sort.Slice(files, func(i, j int) bool {
+		if !files[i].date.Equal(files[j].date) {
+			return files[i].date.Before(files[j].date)
+		}
+		if !files[i].modTime.Equal(files[j].modTime) {
+			return files[i].modTime.Before(files[j].modTime)
+		}
+		return files[i].num < files[j].num
+	})
+	for _, f := range files[:len(files)-w.MaxBackups] {
+		w.removeRotated(f)
 	}
 }
+
+type rotatedFile struct {
+	names   []string // the plain and/or compressed file
+	date    time.Time
+	num     int
+	modTime time.Time
+}
+
+// rotatedFiles lists the files produced by doRotate, counting a file and
+// its compressed form once. The active file and the symlink are never
+// listed, even if their names look rotated; Windows cannot remove a file
+// that is open.
+func (w *fileLogWriter) rotatedFiles() ([]*rotatedFile, error) {
+	entries, err := os.ReadDir(w.filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []*rotatedFile
+	byName := make(map[string]*rotatedFile)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		if path := filepath.Join(w.filePath, name); path == w.Filename || path == w.Symlink {
+			continue
+		}
+		date, num, ok := w.parseRotated(name)
+		if !ok {
+			continue
+		}
+		base := strings.TrimSuffix(name, ".gz")
+		if f, ok := byName[base]; ok {
+			f.names = append(f.names, name)
+			continue
+		}
+		f := &rotatedFile{names: []string{name}, date: date, num: num}
+		if info, err := entry.Info(); err == nil {
+			f.modTime = info.ModTime()
+		}
+		byName[base] = f
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// removeRotated removes the files of f, cancelling their compression. The
+// caller must hold the lock.
+func (w *fileLogWriter) removeRotated(f *rotatedFile) {
+	for _, name := range f.names {
+		w.dropCompressJob(filepath.Join(w.filePath, strings.TrimSuffix(name, ".gz")))
+		if err := os.Remove(filepath.Join(w.filePath, name)); err != nil {
+			w.errOut.printf("FileLogWriter(%q): %s\n", w.Filename, err)
+		}
+	}
+}
+
+// parseRotated parses the date and number out of a name produced by
+// doRotate, i.e. "<name>.2006-01-02<suffix>" or
+// "<name>.2006-01-02.001<suffix>", optionally followed by ".gz".
+func (w *fileLogWriter) parseRotated(name string) (time.Time, int, bool) {
+	name = strings.TrimSuffix(name, ".gz")
+	prefix := filepath.Base(w.fileNameOnly) + "."
+	if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, w.suffix) {
+		return time.Time{}, 0, false
+	}
+	rest := strings.TrimSuffix(strings.TrimPrefix(name, prefix), w.suffix)
+	num := 0
+	if i := strings.IndexByte(rest, '.'); i >= 0 {
+		n, err := strconv.Atoi(rest[i+1:])
+		if err != nil {
+			return time.Time{}, 0, false
+		}
+		num = n
+		rest = rest[:i]
+	}
+	date, err := time.ParseInLocation("2006-01-02", rest, w.now().Location())
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	return date, num, true
+}
+
+func init() {
+	Register(AdapterFile, newFileWriter)
+}
@@ -0,0 +1,1739 @@
+package wlog
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestFileLogger returns a logger with a file output set up from
+// config, a JSON object without the braces naming no filename, writing to
+// app.log in a temp dir. Internal errors go to errOut.
+func newTestFileLogger(t *testing.T, config string) (bl *WLogger, dir string, errOut *syncBuffer) {
+	t.Helper()
+	dir = t.TempDir()
+	bl = NewLogger()
+	errOut = &syncBuffer{}
+	bl.SetErrorOutput(errOut)
+	cfg := fmt.Sprintf(`{"filename":%q`, filepath.Join(dir, "app.log"))
+	if config != "" {
+		cfg += "," + config
+	}
+	if err := bl.SetLogger(AdapterFile, cfg+"}"); err != nil {
+		t.Fatal(err)
+	}
+	return bl, dir, errOut
+}
+
+// listDir returns the names in dir, sorted.
+func listDir(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestMaxBackups(t *testing.T) {
+	tests := []struct {
+		name     string
+		compress bool
+	}{
+		{"plain", false},
+		{"compress", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bl, dir, errOut := newTestFileLogger(t, fmt.Sprintf(`"maxsize":"1KB","maxbackups":2,"compress":%t,"daily":false`, tt.compress))
+			line := strings.Repeat("x", 300)
+			for i := 0; i < 40; i++ {
+				bl.Info("%02d %s", i, line)
+			}
+			bl.Close()
+
+			if s := errOut.String(); s != "" {
+				t.Errorf("errors reported:\n%s", s)
+			}
+			names := listDir(t, dir)
+			if len(names) != 3 {
+				t.Fatalf("files = %v, want app.log and 2 backups", names)
+			}
+			for _, name := range names {
+				if name == "app.log" {
+					continue
+				}
+				if tt.compress != strings.HasSuffix(name, ".gz") {
+					t.Errorf("backup %s, compress %v", name, tt.compress)
+				}
+			}
+			// The backups are the newest: the active file holds the last
+			// lines, the backups those just before.
+			if !strings.Contains(readFile(t, filepath.Join(dir, "app.log")), "39 ") {
+				t.Error("app.log lacks the last line")
+			}
+		})
+	}
+}
+
+// TestFileConfigPaths sets up the same file output from JSON, a map and a
+// FileConfig and expects identical writers and output.
+func TestFileConfigPaths(t *testing.T) {
+	three := LevelError
+	typed := NewFileConfig("")
+	typed.MaxSize = 1 << 20
+	typed.MaxLines = 100
+	typed.Format = FormatJSON
+	typed.SyncLevel = &three
+
+	tests := []struct {
+		name string
+		add  func(bl *WLogger, filename string) error
+	}{
+		{"json", func(bl *WLogger, filename string) error {
+			return bl.SetLogger(AdapterFile, fmt.Sprintf(`{"filename":%q,"maxsize":"1MB","maxlines":100,"format":"json","synclevel":3}`, filename))
+		}},
+		{"map", func(bl *WLogger, filename string) error {
+			return bl.SetLoggerMap(AdapterFile, map[string]interface{}{
+				"filename": filename, "maxsize": "1MB", "maxlines": 100.0, "format": "json", "synclevel": 3,
+			})
+		}},
+		{"typed", func(bl *WLogger, filename string) error {
+			cfg := typed
+			cfg.Filename = filename
+			return bl.SetFileLogger(cfg)
+		}},
+	}
+
+	var want FileConfig
+	var wantOut string
+	for i, tt := range tests {
+		filename := filepath.Join(t.TempDir(), "app.log")
+		bl := NewLogger()
+		if err := tt.add(bl, filename); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		bl.Info("hello %s", "world")
+		got := output(t, bl, AdapterFile).(*fileLogWriter).FileConfig
+		bl.Close()
+
+		got.Filename = ""
+		// The log time differs from run to run.
+		out := readFile(t, filename)
+		out = out[strings.Index(out, `"level"`):]
+		if i == 0 {
+			want, wantOut = got, out
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s config:\n%+v\nwant (json):\n%+v", tt.name, got, want)
+		}
+		if out != wantOut {
+			t.Errorf("%s output %q, want %q", tt.name, out, wantOut)
+		}
+	}
+}
+
+// TestFileConfigLiteral checks that a FileConfig literal gets no defaults
+// NewFileConfig would give: its zero Level writes Emergency alone, as
+// "level":0 does in JSON.
+func TestFileConfigLiteral(t *testing.T) {
+	dir := t.TempDir()
+	literal := filepath.Join(dir, "sub", "literal.log")
+	json := filepath.Join(dir, "json.log")
+	defaults := filepath.Join(dir, "defaults.log")
+	write := func(set func(bl *WLogger) error) {
+		t.Helper()
+		bl := NewLogger()
+		if err := set(bl); err != nil {
+			t.Fatal(err)
+		}
+		bl.Debug("debug line")
+		bl.Emergency("emergency line")
+		bl.Close()
+	}
+	write(func(bl *WLogger) error {
+		return bl.SetFileLogger(FileConfig{Filename: literal, CreateDirs: true})
+	})
+	write(func(bl *WLogger) error {
+		return bl.SetLogger(AdapterFile, fmt.Sprintf(`{"filename":%q,"level":0}`, json))
+	})
+	write(func(bl *WLogger) error { return bl.SetFileLogger(NewFileConfig(defaults)) })
+
+	for _, name := range []string{literal, json} {
+		if s := readFile(t, name); strings.Contains(s, "debug line") || !strings.Contains(s, "emergency line") {
+			t.Errorf("%s = %q, want the emergency line alone", filepath.Base(name), s)
+		}
+	}
+	if s := readFile(t, defaults); !strings.Contains(s, "debug line") {
+		t.Errorf("NewFileConfig dropped Debug: %q", s)
+	}
+}
+
+func TestSyncDevice(t *testing.T) {
+	if _, err := os.Stat(os.DevNull); err != nil {
+		t.Skip(err)
+	}
+	bl := NewLogger()
+	if err := bl.SetLogger(AdapterFile, fmt.Sprintf(`{"filename":%q,"rotate":false,"syncalways":true}`, os.DevNull)); err != nil {
+		t.Fatal(err)
+	}
+	defer bl.Close()
+	if err := bl.WriteMsg(LevelInfo, "synced"); err != nil {
+		t.Errorf("WriteMsg = %v", err)
+	}
+	w := output(t, bl, AdapterFile).(*fileLogWriter)
+	if err := w.writeBatch([]*Record{{Time: time.Now(), Level: LevelInfo, Msg: "synced"}}); err != nil {
+		t.Errorf("writeBatch = %v", err)
+	}
+}
+
+// TestSyncLevel counts the syncs of lines and batches: every one with
+// syncalways, those at synclevel and above, and none by default.
+func TestSyncLevel(t *testing.T) {
+	tests := []struct {
+		name, config  string
+		lines, batch  int // syncs for the 4 lines written one by one, for the batch
+		batchNoUrgent int // syncs for a batch of debug and info lines
+	}{
+		{"default", ``, 0, 0, 0},
+		{"always", `"syncalways":true`, 4, 1, 1},
+		{"error", fmt.Sprintf(`"synclevel":%d`, LevelError), 2, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := `"daily":false`
+			if tt.config != "" {
+				config += "," + tt.config
+			}
+			bl, _, _ := newTestFileLogger(t, config)
+			defer bl.Close()
+			w := output(t, bl, AdapterFile).(*fileLogWriter)
+			syncs := 0
+			w.syncFile = func(f *os.File) error {
+				syncs++
+				return f.Sync()
+			}
+
+			levels := []int{LevelDebug, LevelInfo, LevelError, LevelCritical}
+			for _, level := range levels {
+				bl.WriteMsg(level, "line")
+			}
+			if syncs != tt.lines {
+				t.Errorf("%d syncs for single lines, want %d", syncs, tt.lines)
+			}
+
+			syncs = 0
+			var batch []*Record
+			for _, level := range levels {
+				batch = append(batch, &Record{Time: time.Now(), Level: level, Msg: "batched"})
+			}
+			if err := w.writeBatch(batch); err != nil {
+				t.Fatal(err)
+			}
+			if syncs != tt.batch {
+				t.Errorf("%d syncs for a batch, want %d", syncs, tt.batch)
+			}
+
+			syncs = 0
+			if err := w.writeBatch(batch[:2]); err != nil {
+				t.Fatal(err)
+			}
+			if syncs != tt.batchNoUrgent {
+				t.Errorf("%d syncs for a debug and info batch, want %d", syncs, tt.batchNoUrgent)
+			}
+		})
+	}
+}
+
+func TestLevelFileStats(t *testing.T) {
+	dir := t.TempDir()
+	bl := NewLogger()
+	if err := bl.SetLogger(AdapterFile, fmt.Sprintf(`{"filename":%q}`, filepath.Join(dir, "app.{level}.log"))); err != nil {
+		t.Fatal(err)
+	}
+	defer bl.Close()
+	bl.Info("one")
+	bl.Info("two")
+	bl.Error("three")
+
+	fs, ok := bl.FileStats(AdapterFile)
+	if !ok {
+		t.Fatal("no stats")
+	}
+	tests := []struct {
+		level string
+		lines int
+	}{
+		{LevelName(LevelInfo), 2},
+		{LevelName(LevelError), 1},
+	}
+	if len(fs.Files) != len(tests) {
+		t.Fatalf("Files = %v, want %d entries", fs.Files, len(tests))
+	}
+	size := 0
+	for _, tt := range tests {
+		s := fs.Files[tt.level]
+		if s.Lines != tt.lines || s.Size == 0 || !strings.Contains(s.Filename, tt.level) {
+			t.Errorf("Files[%s] = %+v, want %d lines", tt.level, s, tt.lines)
+		}
+		size += s.Size
+	}
+	if fs.Lines != 3 || fs.Size != size {
+		t.Errorf("totals = %d lines, %d bytes, want 3 lines, %d bytes", fs.Lines, fs.Size, size)
+	}
+
+	// Each file holds the lines of its level alone.
+	bl.Flush()
+	files := []struct {
+		name, want string
+	}{
+		{"app.info.log", "[I] one\n[I] two\n"},
+		{"app.error.log", "[E] three\n"},
+	}
+	for _, f := range files {
+		var got string
+		for _, line := range strings.SplitAfter(readFile(t, filepath.Join(dir, f.name)), "\n") {
+			if i := strings.Index(line, "["); i >= 0 {
+				got += line[i:]
+			}
+		}
+		if got != f.want {
+			t.Errorf("%s holds %q, want %q", f.name, got, f.want)
+		}
+	}
+}
+
+// TestLevelSymlink checks that each level file gets a link of its own, and
+// that a link shared by all level files is refused.
+func TestLevelSymlink(t *testing.T) {
+	dir := t.TempDir()
+	bl := NewLogger()
+	bl.SetErrorOutput(&syncBuffer{})
+	shared := fmt.Sprintf(`{"filename":%q,"symlink":%q}`, filepath.Join(dir, "app.{level}.log"), filepath.Join(dir, "current.log"))
+	if err := bl.SetLogger(AdapterFile, shared); err == nil || !strings.Contains(err.Error(), "{level}") {
+		t.Errorf("shared symlink: err = %v, want one about {level}", err)
+	}
+
+	errOut := &syncBuffer{}
+	bl.SetErrorOutput(errOut)
+	if err := bl.SetLogger(AdapterFile, fmt.Sprintf(`{"filename":%q,"symlink":%q}`, filepath.Join(dir, "app.{level}.log"), filepath.Join(dir, "current.{level}.log"))); err != nil {
+		t.Fatal(err)
+	}
+	bl.Info("info line")
+	bl.Error("error line")
+	bl.Close()
+	if strings.Contains(errOut.String(), "symlink") {
+		t.Skipf("no symlinks: %s", errOut.String())
+	}
+	for _, level := range []string{"info", "error"} {
+		if s := readFile(t, filepath.Join(dir, "current."+level+".log")); !strings.Contains(s, level+" line") || strings.Count(s, "\n") != 1 {
+			t.Errorf("current.%s.log shows %q, want the %s line alone", level, s, level)
+		}
+	}
+}
+
+// fakeClock is a TimerClock whose time only moves on advance.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []fakeTimer
+}
+
+type fakeTimer struct {
+	at time.Time
+	c  chan time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	c.timers = append(c.timers, fakeTimer{c.now.Add(d), ch})
+	return ch
+}
+
+// waitTimers waits until n timers are pending.
+func (c *fakeClock) waitTimers(t *testing.T, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		c.mu.Lock()
+		pending := len(c.timers)
+		c.mu.Unlock()
+		if pending >= n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d timers pending, want %d", pending, n)
+		}
+	}
+}
+
+// advance moves the time on by d, firing the timers due.
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.timers[:0]
+	for _, tm := range c.timers {
+		if tm.at.After(c.now) {
+			pending = append(pending, tm)
+			continue
+		}
+		tm.c <- c.now
+	}
+	c.timers = pending
+}
+
+func TestDailyTimers(t *testing.T) {
+	for _, setFirst := range []bool{true, false} {
+		t.Run(fmt.Sprintf("clockfirst=%v", setFirst), func(t *testing.T) {
+			dir := t.TempDir()
+			// Kept when opened on March 1st, past maxage the day after.
+			old := filepath.Join(dir, "app.2026-02-26.log")
+			if err := os.WriteFile(old, []byte("old\n"), 0666); err != nil {
+				t.Fatal(err)
+			}
+			clock := &fakeClock{now: time.Date(2026, 3, 1, 23, 59, 0, 0, time.Local)}
+			bl := NewLogger()
+			bl.SetErrorOutput(&syncBuffer{})
+			if setFirst {
+				bl.SetClock(clock)
+			}
+			if err := bl.SetLogger(AdapterFile, fmt.Sprintf(`{"filename":%q,"daily":true,"maxage":3}`, filepath.Join(dir, "app.log"))); err != nil {
+				t.Fatal(err)
+			}
+			defer bl.Close()
+			if !setFirst {
+				// Set later, the clock restarts the loops' timers. The
+				// file was then opened, and old cleaned up, on the
+				// system clock.
+				bl.SetClock(clock)
+			}
+			bl.Info("before midnight")
+
+			// One timer each for rotation and cleanup.
+			clock.waitTimers(t, 2)
+			if _, err := os.Stat(old); setFirst && err != nil {
+				t.Fatalf("deleted before midnight: %v", err)
+			}
+			clock.advance(2 * time.Minute)
+
+			for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+				names := listDir(t, dir)
+				rotated := false
+				for _, name := range names {
+					rotated = rotated || name != "app.log" && name != filepath.Base(old)
+				}
+				_, err := os.Stat(old)
+				if rotated && os.IsNotExist(err) {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("files = %v, want app.log rotated and %s deleted", names, filepath.Base(old))
+				}
+			}
+		})
+	}
+}
+
+func TestDeleteOldLog(t *testing.T) {
+	dir := t.TempDir()
+	files := []struct {
+		name string
+		kept bool
+	}{
+		{"app.log", true},
+		{"app.2026-02-20.log", false},
+		{"app.2026-02-20.003.log", false},
+		{"app.2026-02-20.log.gz", false},
+		{"app.2026-02-26.log", true},
+		{"app.2026-03-01.001.log", true},
+		// Not ours: other prefixes, suffixes and dates that do not parse.
+		{"other.2026-02-20.log", true},
+		{"app.2026-02-20.txt", true},
+		{"app.backup.log", true},
+		{"app.2026-02-20.x.log", true},
+	}
+	for _, f := range files {
+		if err := os.WriteFile(filepath.Join(dir, f.name), nil, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "app.2026-01-01.log"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	w := newFileWriter().(*fileLogWriter)
+	w.clock.set(&fakeClock{now: time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)})
+	if err := w.Init(fmt.Sprintf(`{"filename":%q,"maxage":3}`, filepath.Join(dir, "app.log"))); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Destroy()
+	w.deleteOldLog()
+
+	for _, f := range files {
+		_, err := os.Stat(filepath.Join(dir, f.name))
+		if kept := err == nil; kept != f.kept {
+			t.Errorf("%s kept = %v, want %v", f.name, kept, f.kept)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "app.2026-01-01.log")); err != nil {
+		t.Errorf("directory removed: %v", err)
+	}
+}
+
+func TestCompress(t *testing.T) {
+	bl, dir, errOut := newTestFileLogger(t, `"maxsize":"1KB","compress":true,"daily":false`)
+	line := strings.Repeat("y", 300)
+	for i := 0; i < 5; i++ {
+		bl.Info("%02d %s", i, line)
+	}
+	bl.Close()
+	if s := errOut.String(); s != "" {
+		t.Errorf("errors reported:\n%s", s)
+	}
+
+	var all string
+	gzipped := 0
+	for _, name := range listDir(t, dir) {
+		path := filepath.Join(dir, name)
+		if !strings.HasSuffix(name, ".gz") {
+			if name != "app.log" {
+				t.Errorf("%s left uncompressed", name)
+			}
+			all += readFile(t, path)
+			continue
+		}
+		gzipped++
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		b, err := io.ReadAll(zr)
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		all += string(b)
+	}
+	if gzipped == 0 {
+		t.Fatal("no compressed backup")
+	}
+	for i := 0; i < 5; i++ {
+		if !strings.Contains(all, fmt.Sprintf("%02d %s", i, line)) {
+			t.Errorf("line %d lost", i)
+		}
+	}
+}
+
+func TestBufferedClose(t *testing.T) {
+	bl, dir, _ := newTestFileLogger(t, `"bufferkb":64`)
+	for i := 0; i < 100; i++ {
+		bl.Info("line %d", i)
+	}
+	name := filepath.Join(dir, "app.log")
+	if s := readFile(t, name); strings.Contains(s, "line 0") {
+		t.Errorf("written before the buffer filled:\n%s", s)
+	}
+	bl.Close()
+	if n := strings.Count(readFile(t, name), "\n"); n != 100 {
+		t.Errorf("%d lines after Close, want 100", n)
+	}
+}
+
+func BenchmarkFileWrite(b *testing.B) {
+	for _, kb := range []int{0, 64} {
+		b.Run(fmt.Sprintf("bufferkb=%d", kb), func(b *testing.B) {
+			bl := NewLogger()
+			if err := bl.SetLogger(AdapterFile, fmt.Sprintf(`{"filename":%q,"rotate":false,"bufferkb":%d}`, filepath.Join(b.TempDir(), "app.log"), kb)); err != nil {
+				b.Fatal(err)
+			}
+			defer bl.Close()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				bl.Info("benchmark message %d", i)
+			}
+		})
+	}
+}
+
+// BenchmarkFileWriteContended logs from 16 goroutines to one file output,
+// which takes its lock once per message, rotation checks included.
+func BenchmarkFileWriteContended(b *testing.B) {
+	for _, bb := range []struct {
+		name, config string
+	}{
+		{"norotate", `"rotate":false`},
+		{"rotate", `"maxsize":"1GB","maxlines":100000000`},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			bl := NewLogger()
+			if err := bl.SetLogger(AdapterFile, fmt.Sprintf(`{"filename":%q,"bufferkb":64,%s}`, filepath.Join(b.TempDir(), "app.log"), bb.config)); err != nil {
+				b.Fatal(err)
+			}
+			defer bl.Close()
+			const goroutines = 16
+			b.ReportAllocs()
+			b.ResetTimer()
+			var wg sync.WaitGroup
+			for g := 0; g < goroutines; g++ {
+				wg.Add(1)
+				go func(n int) {
+					defer wg.Done()
+					for i := 0; i < n; i++ {
+						bl.Info("benchmark message %d", i)
+					}
+				}((b.N + g) / goroutines)
+			}
+			wg.Wait()
+		})
+	}
+}
+
+// BenchmarkAsyncBatch logs to an async file output from parallel
+// goroutines, writing each message on its own or in batches.
+func BenchmarkAsyncBatch(b *testing.B) {
+	for _, size := range []int{1, 64} {
+		b.Run(fmt.Sprintf("batch=%d", size), func(b *testing.B) {
+			bl := NewLogger()
+			if err := bl.SetLogger(AdapterFile, fmt.Sprintf(`{"filename":%q,"rotate":false}`, filepath.Join(b.TempDir(), "app.log"))); err != nil {
+				b.Fatal(err)
+			}
+			if size > 1 {
+				bl.SetBatch(size, time.Millisecond)
+			}
+			bl.Async(10000)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					bl.Info("benchmark message")
+				}
+			})
+			bl.Close()
+		})
+	}
+}
+
+func TestAsyncBatch(t *testing.T) {
+	bl, dir, _ := newTestFileLogger(t, "")
+	bl.SetBatch(8, time.Millisecond)
+	bl.Async()
+	for i := 0; i < 100; i++ {
+		bl.Info("msg %d", i)
+	}
+	bl.Close()
+	lines := strings.Split(strings.TrimSuffix(readFile(t, filepath.Join(dir, "app.log")), "\n"), "\n")
+	if len(lines) != 100 {
+		t.Fatalf("%d lines, want 100", len(lines))
+	}
+	for i, line := range lines {
+		if !strings.HasSuffix(line, fmt.Sprintf(" msg %d", i)) {
+			t.Fatalf("line %d = %q", i, line)
+		}
+	}
+}
+
+func TestUntilMidnightDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	tests := []struct {
+		name string
+		now  time.Time
+		want time.Duration
+	}{
+		{"plain day", time.Date(2026, 3, 6, 12, 0, 0, 0, loc), 12 * time.Hour},
+		{"before spring forward", time.Date(2026, 3, 7, 12, 0, 0, 0, loc), 12 * time.Hour},
+		{"spring forward day", time.Date(2026, 3, 8, 0, 30, 0, 0, loc), 22*time.Hour + 30*time.Minute},
+		{"fall back day", time.Date(2026, 11, 1, 0, 30, 0, 0, loc), 24*time.Hour + 30*time.Minute},
+		{"at midnight", time.Date(2026, 3, 8, 0, 0, 0, 0, loc), 23 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := untilMidnight(tt.now); got != tt.want {
+			t.Errorf("%s: untilMidnight(%s) = %s, want %s", tt.name, tt.now, got, tt.want)
+		}
+	}
+
+	// Stepping from midnight to midnight across the change lands on each
+	// day's midnight once.
+	now := time.Date(2026, 3, 6, 15, 0, 0, 0, loc)
+	for day := 7; day <= 10; day++ {
+		now = now.Add(untilMidnight(now))
+		if now.Day() != day || now.Hour() != 0 || now.Minute() != 0 {
+			t.Fatalf("step to %s, want March %d 00:00", now, day)
+		}
+	}
+}
+
+// TestDestroyStopsLoops checks that the background loops of a file output
+// have exited once Destroy returns, and that none run without rotation.
+func TestDestroyStopsLoops(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		loops  int32
+	}{
+		{"all", `"daily":true,"maxage":3,"bufferkb":4`, 3},
+		{"buffer", `"rotate":false,"bufferkb":4`, 1},
+		{"norotate", `"rotate":false,"daily":true,"maxage":3`, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newFileWriter().(*fileLogWriter)
+			if err := w.Init(fmt.Sprintf(`{"filename":%q,%s}`, filepath.Join(t.TempDir(), "app.log"), tt.config)); err != nil {
+				t.Fatal(err)
+			}
+			if n := w.running.Load(); n != tt.loops {
+				t.Errorf("%d loops started, want %d", n, tt.loops)
+			}
+			w.Destroy()
+			if n := w.running.Load(); n != 0 {
+				t.Errorf("%d loops left after Destroy", n)
+			}
+		})
+	}
+}
+
+// TestRotateAtAfterDestroy runs a midnight rotation that lost the race with
+// Destroy: it must neither rename the file nor open a new one.
+func TestRotateAtAfterDestroy(t *testing.T) {
+	dir := t.TempDir()
+	w := newFileWriter().(*fileLogWriter)
+	if err := w.Init(fmt.Sprintf(`{"filename":%q}`, filepath.Join(dir, "app.log"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteMsg(time.Now(), "line", LevelInfo); err != nil {
+		t.Fatal(err)
+	}
+	w.Destroy()
+	w.Lock()
+	err := w.rotateAt(time.Now().AddDate(0, 0, 1))
+	w.Unlock()
+	if err != nil {
+		t.Errorf("rotateAt after Destroy: %v", err)
+	}
+	if w.fileWriter != nil {
+		t.Error("file opened after Destroy")
+	}
+	if got := listDir(t, dir); !reflect.DeepEqual(got, []string{"app.log"}) {
+		t.Errorf("files = %v, want app.log alone", got)
+	}
+}
+
+func TestFileUTC(t *testing.T) {
+	setLocal(t, time.FixedZone("AEST", 10*3600))
+	dir := t.TempDir()
+	clock := &fakeClock{now: time.Date(2026, 3, 1, 20, 0, 0, 0, time.UTC)}
+	bl := NewLogger()
+	bl.SetClock(clock)
+	if err := bl.SetLogger(AdapterFile, fmt.Sprintf(`{"filename":%q,"utc":true,"daily":true}`, filepath.Join(dir, "app.log"))); err != nil {
+		t.Fatal(err)
+	}
+	defer bl.Close()
+	bl.Info("before")
+	if got := readFile(t, filepath.Join(dir, "app.log")); !strings.HasPrefix(got, "2026-03-01 20:00:00 [I] before") {
+		t.Errorf("header not in UTC: %q", got)
+	}
+
+	// Past midnight UTC, 10:30 local: the file of March 1st UTC is rotated.
+	// One timer each for rotation and cleanup.
+	clock.waitTimers(t, 2)
+	clock.advance(4*time.Hour + 30*time.Minute)
+	rotated := filepath.Join(dir, "app.2026-03-01.log")
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		if _, err := os.Stat(rotated); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("files = %v, want %s", listDir(t, dir), filepath.Base(rotated))
+		}
+	}
+}
+
+func TestSymlink(t *testing.T) {
+	link := filepath.Join(t.TempDir(), "current.log")
+	bl, dir, errOut := newTestFileLogger(t, fmt.Sprintf(`"symlink":%q,"maxsize":"1KB","daily":false`, link))
+	line := strings.Repeat("z", 300)
+	for i := 0; i < 10; i++ {
+		bl.Info("%02d %s", i, line)
+	}
+	bl.Close()
+	if strings.Contains(errOut.String(), "symlink") {
+		t.Skipf("no symlinks: %s", errOut.String())
+	}
+
+	target, err := filepath.EvalSymlinks(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	active, _ := filepath.EvalSymlinks(filepath.Join(dir, "app.log"))
+	if target != active {
+		t.Errorf("symlink resolves to %s, want %s", target, active)
+	}
+	// The file rotated away holds the first lines, the link the last.
+	if s := readFile(t, link); !strings.Contains(s, "[I] 09 ") || strings.Contains(s, "[I] 00 ") {
+		t.Errorf("symlink does not show the newest file:\n%.80s", s)
+	}
+	if len(listDir(t, dir)) < 2 {
+		t.Error("no rotation happened")
+	}
+}
+
+func TestLevelRouting(t *testing.T) {
+	dir := t.TempDir()
+	bl := NewLogger()
+	outputs := []struct {
+		name, config string
+	}{
+		{"app", `{"filename":%q}`},
+		{"errors", `{"filename":%q,"maxlevel":3}`},
+		{"chatter", `{"filename":%q,"minlevel":4}`},
+	}
+	for _, o := range outputs {
+		if err := bl.SetNamedLogger(o.name, AdapterFile, fmt.Sprintf(o.config, filepath.Join(dir, o.name+".log"))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bl.Info("info line")
+	bl.Error("error line")
+	bl.Close()
+
+	tests := []struct {
+		file       string
+		info, errs bool
+	}{
+		{"app", true, true},
+		{"errors", false, true},
+		{"chatter", true, false},
+	}
+	for _, tt := range tests {
+		s := readFile(t, filepath.Join(dir, tt.file+".log"))
+		if strings.Contains(s, "info line") != tt.info || strings.Contains(s, "error line") != tt.errs {
+			t.Errorf("%s.log = %q, want info %v, error %v", tt.file, s, tt.info, tt.errs)
+		}
+	}
+}
+
+// TestFlushError closes the file under the output so that its sync fails,
+// which Flush must report rather than drop.
+func TestFlushError(t *testing.T) {
+	for _, callback := range []bool{false, true} {
+		t.Run(fmt.Sprintf("callback=%v", callback), func(t *testing.T) {
+			bl, _, errOut := newTestFileLogger(t, `"daily":false`)
+			defer bl.Close()
+			var got []error
+			if callback {
+				bl.OnError(func(err error) { got = append(got, err) })
+			}
+			bl.Info("line")
+			w := output(t, bl, AdapterFile).(*fileLogWriter)
+			w.Lock()
+			w.fileWriter.Close()
+			w.Unlock()
+
+			bl.Flush()
+			if callback {
+				if len(got) != 1 || !errors.Is(got[0], os.ErrClosed) || !strings.Contains(got[0].Error(), "adapter file") {
+					t.Errorf("OnError got %v, want one closed file error of adapter file", got)
+				}
+			} else if s := errOut.String(); !strings.Contains(s, "adapter:file") || !strings.Contains(s, os.ErrClosed.Error()) {
+				t.Errorf("error output %q, want the sync error", s)
+			}
+			if n := bl.Stats().Errors; n != 1 {
+				t.Errorf("Stats.Errors = %d, want 1", n)
+			}
+			if err := w.flushErr(); !errors.Is(err, os.ErrClosed) {
+				t.Errorf("flushErr = %v, want %v", err, os.ErrClosed)
+			}
+		})
+	}
+}
+
+// TestNamedFileFormats adds the file adapter twice, writing text to app.log
+// and JSON to app.json, the latter rotating on its own.
+func TestNamedFileFormats(t *testing.T) {
+	dir := t.TempDir()
+	bl := NewLogger()
+	text := filepath.Join(dir, "app.log")
+	if err := bl.SetNamedLogger("text", AdapterFile, fmt.Sprintf(`{"filename":%q,"daily":false}`, text)); err != nil {
+		t.Fatal(err)
+	}
+	if err := bl.SetNamedLogger("json", AdapterFile, fmt.Sprintf(`{"filename":%q,"format":"json","maxlines":2,"daily":false}`, filepath.Join(dir, "app.json"))); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		bl.Info("line %d", i)
+	}
+	bl.Close()
+
+	if s := readFile(t, text); strings.Count(s, "[I] line") != 3 {
+		t.Errorf("app.log = %q, want 3 text lines", s)
+	}
+	var jsonFiles []string
+	for _, name := range listDir(t, dir) {
+		if strings.HasSuffix(name, ".json") {
+			jsonFiles = append(jsonFiles, name)
+		}
+	}
+	if len(jsonFiles) != 2 {
+		t.Fatalf("JSON files %v, want app.json and one rotated", jsonFiles)
+	}
+	var msgs []string
+	for _, name := range jsonFiles {
+		for _, line := range strings.Split(strings.TrimSpace(readFile(t, filepath.Join(dir, name))), "\n") {
+			var rec struct{ Msg string }
+			if err := json.Unmarshal([]byte(line), &rec); err != nil {
+				t.Fatalf("%s: %q is not JSON: %v", name, line, err)
+			}
+			msgs = append(msgs, rec.Msg)
+		}
+	}
+	sort.Strings(msgs)
+	if want := []string{"line 0", "line 1", "line 2"}; !reflect.DeepEqual(msgs, want) {
+		t.Errorf("JSON messages %q, want %q", msgs, want)
+	}
+}
+
+// TestRotateFailure removes the log directory so that rotation and reopening
+// fail, then keeps using the logger: nothing may panic.
+func TestRotateFailure(t *testing.T) {
+	bl, dir, errOut := newTestFileLogger(t, `"maxsize":"1KB","daily":false`)
+	line := strings.Repeat("x", 300)
+	bl.Info("%s", line)
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		bl.Info("%s", line)
+	}
+	bl.Flush()
+	if err := bl.Reopen(); err == nil {
+		t.Error("Reopen in a removed directory succeeded")
+	}
+	bl.Info("lost")
+	if !strings.Contains(errOut.String(), "FileLogWriter") {
+		t.Errorf("rotation failure not reported:\n%s", errOut.String())
+	}
+
+	// With the directory back, the output recovers.
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := bl.Reopen(); err != nil {
+		t.Fatalf("Reopen: %v", err)
+	}
+	bl.Info("recovered")
+	bl.Close()
+	bl.Close()
+	if s := readFile(t, filepath.Join(dir, "app.log")); !strings.Contains(s, "recovered") {
+		t.Errorf("app.log = %q, want the line after recovery", s)
+	}
+}
+
+// TestNoTornLines logs long lines from many goroutines and checks that every
+// line in the file and in a writer output comes out whole.
+func TestNoTornLines(t *testing.T) {
+	bl, dir, _ := newTestFileLogger(t, `"daily":false`)
+	var buf syncBuffer
+	bl.AddWriter(&buf, LevelDebug)
+
+	const goroutines, lines = 8, 200
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			body := strings.Repeat(string(rune('a'+g)), 500)
+			for i := 0; i < lines; i++ {
+				bl.Info("<%s>", body)
+			}
+		}(g)
+	}
+	wg.Wait()
+	bl.Close()
+
+	outputs := map[string]string{
+		"file":   readFile(t, filepath.Join(dir, "app.log")),
+		"writer": buf.String(),
+	}
+	for name, s := range outputs {
+		got := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+		if len(got) != goroutines*lines {
+			t.Errorf("%s: %d lines, want %d", name, len(got), goroutines*lines)
+		}
+		for _, line := range got {
+			i, j := strings.IndexByte(line, '<'), strings.IndexByte(line, '>')
+			if i < 0 || j != len(line)-1 || strings.Trim(line[i+1:j], line[i+1:i+2]) != "" || j-i-1 != 500 {
+				t.Fatalf("%s: torn line %q", name, line)
+			}
+		}
+	}
+}
+
+// TestRotateAfterRestart starts a writer over a dated set left by an earlier
+// process: rotation must continue the numbering, never overwrite.
+func TestRotateAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	date := now.Format("2006-01-02")
+	old := map[string]string{
+		"app." + date + ".log":     "plain",
+		"app." + date + ".001.log": "one",
+		"app." + date + ".003.log": "three",
+	}
+	for name, s := range old {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(s), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	bl := NewLogger()
+	cfg := fmt.Sprintf(`{"filename":%q,"maxsize":"1KB","daily":false}`, filepath.Join(dir, "app.log"))
+	if err := bl.SetLogger(AdapterFile, cfg); err != nil {
+		t.Fatal(err)
+	}
+	w := output(t, bl, AdapterFile).(*fileLogWriter)
+
+	tests := []struct {
+		name  string
+		plain bool
+	}{
+		{"size", false},
+		{"daily", true},
+	}
+	for _, tt := range tests {
+		got, err := w.nextRotatedName(now, tt.plain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := filepath.Join(dir, "app."+date+".004.log"); got != want {
+			t.Errorf("%s: next name %s, want %s", tt.name, got, want)
+		}
+	}
+
+	line := strings.Repeat("x", 300)
+	for i := 0; i < 5; i++ {
+		bl.Info("%s", line)
+	}
+	bl.Close()
+	for name, s := range old {
+		if got := readFile(t, filepath.Join(dir, name)); got != s {
+			t.Errorf("%s overwritten: %q", name, got)
+		}
+	}
+	if s := readFile(t, filepath.Join(dir, "app."+date+".004.log")); !strings.Contains(s, line) {
+		t.Errorf("rotated file = %q, want the logged lines", s)
+	}
+}
+
+// TestFileStats checks that the reported size and line count grow with
+// every write and that an unknown output is reported as missing.
+func TestFileStats(t *testing.T) {
+	bl, dir, _ := newTestFileLogger(t, `"daily":false`)
+	defer bl.Close()
+	if _, ok := bl.FileStats("nosuch"); ok {
+		t.Error("FileStats of a missing output reported ok")
+	}
+	prev, ok := bl.FileStats(AdapterFile)
+	if !ok {
+		t.Fatal("no stats for the file output")
+	}
+	if want := filepath.Join(dir, "app.log"); prev.Filename != want {
+		t.Errorf("Filename = %s, want %s", prev.Filename, want)
+	}
+	for i := 1; i <= 3; i++ {
+		bl.Info("line %d", i)
+		fs, _ := bl.FileStats(AdapterFile)
+		if fs.Size <= prev.Size || fs.Lines != i {
+			t.Errorf("after %d lines: size %d (was %d), lines %d", i, fs.Size, prev.Size, fs.Lines)
+		}
+		prev = fs
+	}
+	bl.Flush()
+	if s := readFile(t, prev.Filename); len(s) != prev.Size {
+		t.Errorf("file holds %d bytes, stats report %d", len(s), prev.Size)
+	}
+}
+
+// TestReopen renames the log file away as logrotate does: after Reopen new
+// lines go to a fresh file at the original name.
+func TestReopen(t *testing.T) {
+	bl, dir, _ := newTestFileLogger(t, `"daily":false`)
+	name := filepath.Join(dir, "app.log")
+	moved := filepath.Join(dir, "app.log.1")
+	bl.Info("before")
+	bl.Flush()
+	if err := os.Rename(name, moved); err != nil {
+		t.Fatal(err)
+	}
+	bl.Info("renamed")
+	if err := bl.Reopen(); err != nil {
+		t.Fatalf("Reopen: %v", err)
+	}
+	bl.Info("after")
+	bl.Close()
+
+	if s := readFile(t, moved); !strings.Contains(s, "before") || !strings.Contains(s, "renamed") || strings.Contains(s, "after") {
+		t.Errorf("moved file = %q, want the lines before Reopen", s)
+	}
+	if s := readFile(t, name); !strings.Contains(s, "after") || strings.Contains(s, "before") {
+		t.Errorf("new file = %q, want only the lines after Reopen", s)
+	}
+	if err := bl.Reopen(); err != nil {
+		t.Errorf("Reopen after Close: %v", err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name  string
+		spoil func(t *testing.T, name string)
+		err   string
+	}{
+		{"good", func(t *testing.T, name string) {}, ""},
+		{"removed", func(t *testing.T, name string) {
+			if err := os.Remove(name); err != nil {
+				t.Fatal(err)
+			}
+		}, "app.log"},
+		{"directory", func(t *testing.T, name string) {
+			if err := os.Remove(name); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Mkdir(name, 0o755); err != nil {
+				t.Fatal(err)
+			}
+		}, "app.log"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bl, dir, _ := newTestFileLogger(t, `"daily":false`)
+			defer bl.Close()
+			tt.spoil(t, filepath.Join(dir, "app.log"))
+
+			err := bl.Check()
+			if tt.err == "" {
+				if err != nil {
+					t.Errorf("Check() = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), "adapter file") || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Check() = %v, want an error for the file adapter about %s", err, tt.err)
+			}
+		})
+	}
+
+	bl := NewLogger()
+	bl.Close()
+	if err := bl.Check(); err == nil {
+		t.Error("Check() on a closed logger succeeded")
+	}
+}
+
+// TestRotateAtMaxSize pins the byte at which size rotation happens: a line
+// that would take the file past maxsize goes to a new file, one that just
+// reaches it stays.
+func TestRotateAtMaxSize(t *testing.T) {
+	// Each line is "12:00:00 [I] " (13 bytes), the 36 byte message and a
+	// newline: 50 bytes.
+	msg := strings.Repeat("m", 36)
+	tests := []struct {
+		maxsize int
+		lines   []int // per file, oldest first and app.log last
+	}{
+		{150, []int{3}},
+		{100, []int{2, 1}},
+		{99, []int{1, 1, 1}},
+		{51, []int{1, 1, 1}},
+		{30, []int{1, 1, 1}}, // a line over maxsize gets a file of its own
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.maxsize), func(t *testing.T) {
+			bl, dir, errOut := newTestFileLogger(t, fmt.Sprintf(`"maxsize":%d,"daily":false,"timeformat":"15:04:05"`, tt.maxsize))
+			bl.SetClock(&fakeClock{now: time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)})
+			for i := 0; i < 3; i++ {
+				bl.Info("%s", msg)
+			}
+			bl.Close()
+			if s := errOut.String(); s != "" {
+				t.Errorf("errors reported:\n%s", s)
+			}
+
+			// Sorted, the dated rotated files come before app.log.
+			names := listDir(t, dir)
+			var lines []int
+			for _, name := range names {
+				s := readFile(t, filepath.Join(dir, name))
+				if len(s) > tt.maxsize && len(s) != 50 {
+					t.Errorf("%s holds %d bytes, over maxsize", name, len(s))
+				}
+				lines = append(lines, strings.Count(s, "\n"))
+			}
+			if !reflect.DeepEqual(lines, tt.lines) {
+				t.Errorf("lines per file %v (%v), want %v", lines, names, tt.lines)
+			}
+		})
+	}
+}
+
+// TestFilePaths sets up file outputs under various names and checks the
+// rotated names and the listing of rotated files built from them.
+func TestFilePaths(t *testing.T) {
+	tests := []struct {
+		filename string // slash-separated, under a temporary directory
+		clean    string
+		rotated  string
+	}{
+		{"app.log", "app.log", "app.2026-03-01.002.log"},
+		{"app", "app", "app.2026-03-01.002.log"}, // rotated files get .log
+		{"app.tar.log", "app.tar.log", "app.tar.2026-03-01.002.log"},
+		{"sub/../app.log", "app.log", "app.2026-03-01.002.log"},
+		{"./logs.d/app.log", "logs.d/app.log", "logs.d/app.2026-03-01.002.log"},
+		{"logs.d//nested/app.log", "logs.d/nested/app.log", "logs.d/nested/app.2026-03-01.002.log"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			dir := t.TempDir()
+			w := newFileWriter().(*fileLogWriter)
+			cfg := fmt.Sprintf(`{"filename":%q,"createdirs":true,"daily":false}`, dir+"/"+tt.filename)
+			if err := w.Init(cfg); err != nil {
+				t.Fatal(err)
+			}
+			defer w.Destroy()
+
+			if want := filepath.Join(dir, filepath.FromSlash(tt.clean)); w.Filename != want {
+				t.Errorf("Filename = %s, want %s", w.Filename, want)
+			}
+			rotated := w.rotatedName("2026-03-01", 2)
+			if want := filepath.Join(dir, filepath.FromSlash(tt.rotated)); rotated != want {
+				t.Errorf("rotated name %s, want %s", rotated, want)
+			}
+			if err := os.WriteFile(rotated, nil, 0666); err != nil {
+				t.Fatal(err)
+			}
+			files, err := w.rotatedFiles()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(files) != 1 || files[0].num != 2 || files[0].names[0] != filepath.Base(rotated) {
+				t.Errorf("rotated files %+v, want %s alone", files, filepath.Base(rotated))
+			}
+		})
+	}
+}
+
+// readGzip returns the uncompressed content of the gzip file name.
+func readGzip(t *testing.T, name string) string {
+	t.Helper()
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	b, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return string(b)
+}
+
+// TestCompressBacklog holds the compression slots while rotating past
+// MaxBackups: writes must not wait for compression, and once it resumes
+// only the newest backups are left, all compressed.
+func TestCompressBacklog(t *testing.T) {
+	for _, numberOnly := range []bool{false, true} {
+		t.Run(fmt.Sprintf("numberonly=%v", numberOnly), func(t *testing.T) {
+			bl, dir, errOut := newTestFileLogger(t, fmt.Sprintf(`"maxlines":1,"maxbackups":2,"compress":true,"numberonly":%v,"daily":false`, numberOnly))
+			for i := 0; i < cap(compressSlots); i++ {
+				compressSlots <- struct{}{}
+			}
+			held := true
+			release := func() {
+				if held {
+					held = false
+					for i := 0; i < cap(compressSlots); i++ {
+						<-compressSlots
+					}
+				}
+			}
+			defer release()
+
+			done := make(chan struct{})
+			go func() {
+				for i := 0; i < 6; i++ {
+					bl.Info("line %d", i)
+				}
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				release()
+				t.Fatal("writes blocked on compression")
+			}
+			release()
+			bl.Close()
+			if s := errOut.String(); s != "" {
+				t.Errorf("errors reported:\n%s", s)
+			}
+
+			var backups []string
+			for _, name := range listDir(t, dir) {
+				switch {
+				case name == "app.log":
+				case strings.HasSuffix(name, ".gz"):
+					backups = append(backups, readGzip(t, filepath.Join(dir, name)))
+				default:
+					t.Errorf("%s left uncompressed", name)
+				}
+			}
+			if len(backups) != 2 {
+				t.Fatalf("%d backups, want 2", len(backups))
+			}
+			// app.1.log sorts before app.2.log, dated names oldest first.
+			newer, older := backups[0], backups[1]
+			if !numberOnly {
+				newer, older = older, newer
+			}
+			if !strings.HasSuffix(newer, "[I] line 4\n") || !strings.HasSuffix(older, "[I] line 3\n") {
+				t.Errorf("backups %q and %q, want lines 4 and 3", newer, older)
+			}
+		})
+	}
+}
+
+// TestBanner checks that every file, the first and those opened by
+// rotation, starts with the banner, and that the banner counts as a line.
+func TestBanner(t *testing.T) {
+	bl, dir, _ := newTestFileLogger(t, `"banner":"svc pid={pid}","maxlines":4,"rotatenotice":true,"daily":false`)
+	for i := 0; i < 6; i++ {
+		bl.Info("line %d", i)
+	}
+	bl.Close()
+
+	banner := fmt.Sprintf("svc pid=%d\n", os.Getpid())
+	names := listDir(t, dir)
+	if len(names) != 3 {
+		t.Fatalf("files = %v, want app.log and 2 rotated", names)
+	}
+	logged := 0
+	for i, name := range names {
+		s := readFile(t, filepath.Join(dir, name))
+		if !strings.HasPrefix(s, banner) {
+			t.Errorf("%s does not start with the banner:\n%s", name, s)
+		}
+		// The files opened by rotation carry the notice after the banner.
+		if notice := strings.Contains(s, "continued from"); notice != (i > 0) {
+			t.Errorf("%s: rotation notice %v:\n%s", name, notice, s)
+		}
+		if n := strings.Count(s, "\n"); n > 4 {
+			t.Errorf("%s holds %d lines, over maxlines", name, n)
+		}
+		logged += strings.Count(s, "] line ")
+	}
+	if logged != 6 {
+		t.Errorf("%d lines logged, want 6", logged)
+	}
+}
+
+// TestCompressOnClose rotates large files in more outputs than there are
+// compression slots and closes right away: Close waits for the queued and
+// running compressions, leaving complete archives and no plain backups.
+func TestCompressOnClose(t *testing.T) {
+	const outputs, lines = 4, 2000
+	dir := t.TempDir()
+	bl := NewLogger()
+	errOut := &syncBuffer{}
+	bl.SetErrorOutput(errOut)
+	for i := 0; i < outputs; i++ {
+		name := fmt.Sprint("app", i)
+		cfg := fmt.Sprintf(`{"filename":%q,"maxsize":"256KB","compress":true,"daily":false}`, filepath.Join(dir, name+".log"))
+		if err := bl.SetNamedLogger(name, AdapterFile, cfg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	line := strings.Repeat("z", 200)
+	for i := 0; i < lines; i++ {
+		bl.Info("%04d %s", i, line)
+	}
+	bl.Close()
+	if s := errOut.String(); s != "" {
+		t.Errorf("errors reported:\n%s", s)
+	}
+
+	counts := make(map[string]int) // lines per output
+	for _, name := range listDir(t, dir) {
+		path := filepath.Join(dir, name)
+		output := name[:strings.IndexByte(name, '.')]
+		if !strings.HasSuffix(name, ".gz") {
+			if name != output+".log" {
+				t.Errorf("%s left uncompressed", name)
+			}
+			counts[output] += strings.Count(readFile(t, path), "\n")
+			continue
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		b, err := io.ReadAll(zr)
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s: truncated archive: %v", name, err)
+		}
+		counts[output] += strings.Count(string(b), "\n")
+	}
+	for i := 0; i < outputs; i++ {
+		if n := counts[fmt.Sprint("app", i)]; n != lines {
+			t.Errorf("app%d: %d lines, want %d", i, n, lines)
+		}
+	}
+}
+
+// writeFixture fills name with n lines of about 100 bytes.
+func writeFixture(tb testing.TB, name string, n int) {
+	tb.Helper()
+	line := strings.Repeat("f", 99) + "\n"
+	if err := os.WriteFile(name, []byte(strings.Repeat(line, n)), 0666); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+func TestSkipLineCount(t *testing.T) {
+	for _, skip := range []bool{false, true} {
+		dir := t.TempDir()
+		name := filepath.Join(dir, "app.log")
+		writeFixture(t, name, 1000)
+		w := newFileWriter().(*fileLogWriter)
+		if err := w.Init(fmt.Sprintf(`{"filename":%q,"maxlines":100000,"skiplinecount":%t}`, name, skip)); err != nil {
+			t.Fatal(err)
+		}
+		want := 1000
+		if skip {
+			want = 0
+		}
+		if fs := w.stats(); fs.Lines != want || fs.Size != 100*1000 {
+			t.Errorf("skiplinecount %v: %d lines, %d bytes, want %d lines", skip, fs.Lines, fs.Size, want)
+		}
+		w.Destroy()
+	}
+}
+
+// BenchmarkOpenLarge opens a file output over a 32MB file with maxlines
+// set, counting its lines or skipping the count.
+func BenchmarkOpenLarge(b *testing.B) {
+	name := filepath.Join(b.TempDir(), "app.log")
+	writeFixture(b, name, 320000)
+	for _, skip := range []bool{false, true} {
+		b.Run(fmt.Sprintf("skiplinecount=%t", skip), func(b *testing.B) {
+			cfg := fmt.Sprintf(`{"filename":%q,"maxlines":1000000,"daily":false,"skiplinecount":%t}`, name, skip)
+			for i := 0; i < b.N; i++ {
+				w := newFileWriter().(*fileLogWriter)
+				if err := w.Init(cfg); err != nil {
+					b.Fatal(err)
+				}
+				w.Destroy()
+			}
+		})
+	}
+}
+
+// TestOnRotate captures the OnRotate calls of a size and a daily rotation.
+func TestOnRotate(t *testing.T) {
+	type rotation struct{ from, to string }
+	tests := []struct {
+		name   string
+		config string
+		roll   func(bl *WLogger, clock *fakeClock)
+		to     string // rotated name
+	}{
+		{"size", `"maxlines":2,"daily":false`, func(bl *WLogger, clock *fakeClock) {
+			bl.Info("two")
+			bl.Info("three")
+		}, "app.2026-03-01.001.log"},
+		{"daily", `"daily":true`, func(bl *WLogger, clock *fakeClock) {
+			clock.advance(24 * time.Hour)
+			bl.Info("next day")
+		}, "app.2026-03-01.log"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			clock := &fakeClock{now: time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)}
+			bl := NewLogger()
+			bl.SetClock(clock)
+			rotations := make(chan rotation, 4)
+			bl.OnRotate(func(from, to string) { rotations <- rotation{from, to} })
+			if err := bl.SetLogger(AdapterFile, fmt.Sprintf(`{"filename":%q,%s}`, filepath.Join(dir, "app.log"), tt.config)); err != nil {
+				t.Fatal(err)
+			}
+			defer bl.Close()
+			bl.Info("one")
+			tt.roll(bl, clock)
+
+			want := rotation{filepath.Join(dir, "app.log"), filepath.Join(dir, tt.to)}
+			select {
+			case got := <-rotations:
+				if got != want {
+					t.Errorf("OnRotate(%s, %s), want (%s, %s)", got.from, got.to, want.from, want.to)
+				}
+				if s := readFile(t, got.to); !strings.Contains(s, "one") {
+					t.Errorf("%s = %q, want the first line", got.to, s)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("OnRotate not called")
+			}
+			select {
+			case got := <-rotations:
+				t.Errorf("second OnRotate(%s, %s)", got.from, got.to)
+			default:
+			}
+		})
+	}
+}
+
+// TestProcessSafe runs processes writing to and rotating the same file
+// with processsafe: no line may be torn, lost or overwritten.
+func TestProcessSafe(t *testing.T) {
+	const procs, lines = 3, 500
+	body := strings.Repeat("p", 200)
+	config := func(name string) string {
+		return fmt.Sprintf(`{"filename":%q,"processsafe":true,"maxsize":"64KB","daily":false}`, name)
+	}
+	if name := os.Getenv("WLOG_TEST_PROCESSSAFE"); name != "" {
+		bl := NewLogger()
+		if err := bl.SetLogger(AdapterFile, config(name)); err != nil {
+			t.Fatal(err)
+		}
+		id := os.Getenv("WLOG_TEST_PROCESS")
+		for i := 0; i < lines; i++ {
+			bl.Info("<%s %d %s>", id, i, body)
+		}
+		bl.Close()
+		return
+	}
+
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+	cmds := make([]*exec.Cmd, procs)
+	for p := range cmds {
+		cmds[p] = exec.Command(os.Args[0], "-test.run=^TestProcessSafe$")
+		cmds[p].Env = append(os.Environ(), "WLOG_TEST_PROCESSSAFE="+name, fmt.Sprint("WLOG_TEST_PROCESS=", p))
+		if err := cmds[p].Start(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, cmd := range cmds {
+		if err := cmd.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, file := range listDir(t, dir) {
+		if strings.HasSuffix(file, ".lock") {
+			continue
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(readFile(t, filepath.Join(dir, file)), "\n"), "\n") {
+			i := strings.IndexByte(line, '<')
+			var id, n int
+			var rest string
+			if i < 0 || !strings.HasSuffix(line, body+">") {
+				t.Fatalf("%s: torn line %q", file, line)
+			}
+			if _, err := fmt.Sscanf(line[i:], "<%d %d %s", &id, &n, &rest); err != nil {
+				t.Fatalf("%s: torn line %q", file, line)
+			}
+			key := fmt.Sprint(id, " ", n)
+			if seen[key] {
+				t.Errorf("line %s written twice", key)
+			}
+			seen[key] = true
+		}
+	}
+	if len(seen) != procs*lines {
+		t.Errorf("%d lines found, want %d", len(seen), procs*lines)
+	}
+}
+
+// TestRotateOnDemand forces rotations below every threshold: each moves
+// the content so far to a new numbered file and starts app.log afresh.
+func TestRotateOnDemand(t *testing.T) {
+	bl, dir, _ := newTestFileLogger(t, `"maxsize":"1MB","daily":false`)
+	bl.SetClock(&fakeClock{now: time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)})
+	for i := 1; i <= 2; i++ {
+		bl.Info("before rotation %d", i)
+		if err := bl.Rotate(); err != nil {
+			t.Fatalf("Rotate: %v", err)
+		}
+	}
+	bl.Info("after")
+	bl.Close()
+	if err := bl.Rotate(); err != nil {
+		t.Errorf("Rotate after Close: %v", err)
+	}
+
+	want := map[string]string{
+		"app.2026-03-01.001.log": "before rotation 1",
+		"app.2026-03-01.002.log": "before rotation 2",
+		"app.log":                "after",
+	}
+	if names := listDir(t, dir); len(names) != len(want) {
+		t.Fatalf("files = %v", names)
+	}
+	for name, line := range want {
+		s := readFile(t, filepath.Join(dir, name))
+		if strings.Count(s, "\n") != 1 || !strings.Contains(s, line) {
+			t.Errorf("%s = %q, want %q alone", name, s, line)
+		}
+	}
+}
+
+// TestNumberOnly rotates three times with NumberOnly: the newest rotated
+// file is always app.1.log, older ones shift up by one, and MaxBackups
+// drops the oldest.
+func TestNumberOnly(t *testing.T) {
+	tests := []struct {
+		name, config string
+		files        []string
+	}{
+		{"all", `"numberonly":true,"maxlines":1,"daily":false`, []string{"app.1.log", "app.2.log", "app.3.log", "app.log"}},
+		{"maxbackups", `"numberonly":true,"maxlines":1,"maxbackups":2,"daily":false`, []string{"app.1.log", "app.2.log", "app.log"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bl, dir, errOut := newTestFileLogger(t, tt.config)
+			for i := 0; i < 4; i++ {
+				bl.Info("line %d", i)
+			}
+			bl.Close()
+			if s := errOut.String(); s != "" {
+				t.Errorf("errors: %s", s)
+			}
+
+			if got := listDir(t, dir); !reflect.DeepEqual(got, tt.files) {
+				t.Fatalf("files = %v, want %v", got, tt.files)
+			}
+			// app.log holds the last line, app.N.log the one N lines before.
+			for n, name := range append([]string{"app.log"}, tt.files[:len(tt.files)-1]...) {
+				if s, want := readFile(t, filepath.Join(dir, name)), fmt.Sprintf("[I] line %d\n", 3-n); !strings.HasSuffix(s, want) {
+					t.Errorf("%s = %q, want %q", name, s, want)
+				}
+			}
+		})
+	}
+}
+
+// TestLoopsStarted checks which settings start the daily rotation and
+// cleanup loops: none run unless rotation and deletion are on.
+func TestLoopsStarted(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		loops  int32
+	}{
+		{"defaults", ``, 2},
+		{"daily keep", `"day":-1`, 1},
+		{"size only", `"daily":false,"maxsize":"1MB"`, 0},
+		{"maxage without daily", `"daily":false,"maxage":3`, 1},
+		{"no rotate", `"rotate":false`, 0},
+		{"no rotate maxage", `"rotate":false,"maxage":3`, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := fmt.Sprintf(`{"filename":%q`, filepath.Join(t.TempDir(), "app.log"))
+			if tt.config != "" {
+				cfg += "," + tt.config
+			}
+			w := newFileWriter().(*fileLogWriter)
+			if err := w.Init(cfg + "}"); err != nil {
+				t.Fatal(err)
+			}
+			defer w.Destroy()
+			if n := w.running.Load(); n != tt.loops {
+				t.Errorf("%d loops started, want %d", n, tt.loops)
+			}
+		})
+	}
+}
+
+func TestCreateDirs(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		created bool
+		mode    os.FileMode
+	}{
+		{"default", ``, true, 0755},
+		{"dirperm", `,"dirperm":"0700"`, true, 0700},
+		{"off", `,"createdirs":false`, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "a", "b")
+			name := filepath.Join(dir, "app.log")
+			w := newFileWriter().(*fileLogWriter)
+			err := w.Init(fmt.Sprintf(`{"filename":%q%s}`, name, tt.config))
+			if !tt.created {
+				if err == nil {
+					w.Destroy()
+					t.Fatal("Init succeeded in a missing directory")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer w.Destroy()
+			fi, err := os.Stat(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The umask may clear bits, never set them.
+			if runtime.GOOS != "windows" && fi.Mode().Perm()&^tt.mode != 0 {
+				t.Errorf("directory mode %v, want at most %v", fi.Mode().Perm(), tt.mode)
+			}
+			if _, err := os.Stat(name); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
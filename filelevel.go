@@ -0,0 +1,96 @@
+package wlog
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+)
+
+// levelFiles backs a file output whose Filename contains "{level}": each
+// level is written to its own file, with the level name in place of the
+// placeholder, opened on first use and rotated and cleaned up on its own.
+type levelFiles struct {
+	sync.Mutex
+	parent    *fileLogWriter
+	files     map[int]*fileLogWriter
+	destroyed bool
+}
+
+func hasLevelTemplate(name string) bool {
+	return strings.Contains(name, "{level}")
+}
+
+// get returns the file for level, opening it if needed.
+func (lf *levelFiles) get(level int) (*fileLogWriter, error) {
+	lf.Lock()
+	defer lf.Unlock()
+	if w, ok := lf.files[level]; ok {
+		return w, nil
+	}
+	if lf.destroyed {
+		return nil, os.ErrClosed
+	}
+
+	p := lf.parent
+	cfg := p.FileConfig
+	name := LevelName(level)
+	cfg.Filename = strings.ReplaceAll(cfg.Filename, "{level}", name)
+	cfg.Symlink = strings.ReplaceAll(cfg.Symlink, "{level}", name)
+	config, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	w := newFileWriter().(*fileLogWriter)
+	if c := p.clock.p.Load(); c != nil {
+		w.clock.set(*c)
+	}
+	if out := p.errOut.p.Load(); out != nil {
+		w.errOut.set(*out)
+	}
+	w.onRotate = p.onRotate
+	if err := w.Init(string(config)); err != nil {
+		return nil, err
+	}
+	lf.files[level] = w
+	return w, nil
+}
+
+// destroy destroys the files opened so far and keeps new ones from being
+// opened.
+func (lf *levelFiles) destroy() {
+	lf.Lock()
+	defer lf.Unlock()
+	lf.destroyed = true
+	for _, w := range lf.files {
+		w.Destroy()
+	}
+}
+
+// each calls f for every file opened so far, returning the first error.
+func (lf *levelFiles) each(f func(w *fileLogWriter) error) error {
+	lf.Lock()
+	defer lf.Unlock()
+	var firstErr error
+	for _, w := range lf.files {
+		if err := f(w); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// stats returns the stats of the output with Filename template, summing
+// those of the files opened so far.
+func (lf *levelFiles) stats(template string) FileStats {
+	lf.Lock()
+	defer lf.Unlock()
+	fs := FileStats{Filename: template, Files: make(map[string]FileStats, len(lf.files))}
+	for level, w := range lf.files {
+		s := w.stats()
+		fs.Files[LevelName(level)] = s
+		fs.Size += s.Size
+		fs.Lines += s.Lines
+	}
+	return fs
+}
@@ -0,0 +1,169 @@
+package wlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// DefaultTimeFormat is the time layout of the text format.
+const DefaultTimeFormat = "2006-01-02 15:04:05"
+
+// TimeRelative as a TimeFormat writes the time elapsed since an epoch,
+// "+12.345" in seconds, instead of the wall clock. The epoch is the
+// formatter's Epoch if set, otherwise the logger's, which is its creation
+// unless changed with SetEpoch.
+const TimeRelative = "relative"
+
+// processStart is the epoch of lines formatted without a logger.
+var processStart = time.Now()
+
+// Formatter renders a single log line, without the trailing newline.
+type Formatter interface {
+	Format(when time.Time, msg string, level int) []byte
+}
+
+// TextFormatter renders "2006-01-02 15:04:05 [I] message". TimeFormat
+// defaults to DefaultTimeFormat. Times are local unless UTC is set.
+type TextFormatter struct {
+	TimeFormat string
+	UTC        bool
+	Epoch      time.Time // for TimeRelative
+}
+
+func (f TextFormatter) Format(when time.Time, msg string, level int) []byte {
+	return f.format(when, nil, msg, nil)
+}
+
+func (f TextFormatter) formatRecord(r *Record) []byte {
+	return f.format(r.Time, r.epoch, r.text(), r.Fields)
+}
+
+func (f TextFormatter) format(when time.Time, epoch *time.Time, msg string, fields []Field) []byte {
+	layout := f.TimeFormat
+	if layout == "" {
+		layout = DefaultTimeFormat
+	}
+	// Leave room for the header and the newline the writers append.
+	b := make([]byte, 0, len(layout)+len(msg)+2)
+	if layout == TimeRelative {
+		b = appendRelative(b, when.Sub(pickEpoch(f.Epoch, epoch)))
+		b = append(b, ' ')
+	} else {
+		b = appendTimeHeader(b, inZone(when, f.UTC), layout)
+	}
+	b = append(b, msg...)
+	return appendFieldsText(b, fields)
+}
+
+// JSONFormatter renders {"time":...,"level":"info","msg":...}. TimeFormat
+// defaults to time.RFC3339. Times are local unless UTC is set.
+type JSONFormatter struct {
+	TimeFormat string
+	UTC        bool
+	Epoch      time.Time // for TimeRelative
+}
+
+type jsonLine struct {
+	Time   string `json:"time"`
+	Level  string `json:"level"`
+	Msg    string `json:"msg"`
+	Caller string `json:"caller,omitempty"`
+	Logger string `json:"logger,omitempty"`
+}
+
+func (f JSONFormatter) Format(when time.Time, msg string, level int) []byte {
+	return f.formatRecord(newTextRecord(when, msg, level))
+}
+
+func (f JSONFormatter) formatRecord(r *Record) []byte {
+	layout := f.TimeFormat
+	if layout == "" {
+		layout = time.RFC3339
+	}
+	var stamp string
+	if layout == TimeRelative {
+		stamp = string(appendRelative(nil, r.Time.Sub(pickEpoch(f.Epoch, r.epoch))))
+	} else {
+		stamp = inZone(r.Time, f.UTC).Format(layout)
+	}
+	b, err := json.Marshal(jsonLine{
+		Time:   stamp,
+		Level:  LevelName(r.Level),
+		Msg:    r.Msg,
+		Caller: r.Caller,
+		Logger: r.Prefix,
+	})
+	if err != nil {
+		return []byte(fmt.Sprintf(`{"level":"error","msg":%q}`, err.Error()))
+	}
+	return appendFieldsJSON(b, r.Fields)
+}
+
+// formatConfig holds the output format settings shared by the adapters
+// that format their own lines.
+type formatConfig struct {
+	Format     string `json:"format"`
+	TimeFormat string `json:"timeformat"`
+	UTC        bool   `json:"utc"`
+
+	// LineSeparator ends each line of text output, "\n" by default. JSON
+	// output always uses "\n", one record per line.
+	LineSeparator string `json:"lineseparator"`
+}
+
+func (c formatConfig) lineSeparator() string {
+	if c.LineSeparator == "" || c.Format == FormatJSON {
+		return "\n"
+	}
+	return c.LineSeparator
+}
+
+func (c formatConfig) newFormatter() (Formatter, error) {
+	switch c.Format {
+	case "", FormatText:
+		return TextFormatter{TimeFormat: c.TimeFormat, UTC: c.UTC}, nil
+	case FormatJSON:
+		return JSONFormatter{TimeFormat: c.TimeFormat, UTC: c.UTC}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q", c.Format)
+	}
+}
+
+// pickEpoch returns the epoch of a TimeRelative stamp: the formatter's, the
+// logger's or the start of the process.
+func pickEpoch(own time.Time, logger *time.Time) time.Time {
+	switch {
+	case !own.IsZero():
+		return own
+	case logger != nil:
+		return *logger
+	}
+	return processStart
+}
+
+// appendRelative appends d as "+S.mmm".
+func appendRelative(b []byte, d time.Duration) []byte {
+	sign := byte('+')
+	if d < 0 {
+		sign, d = '-', -d
+	}
+	ms := d.Milliseconds()
+	b = append(b, sign)
+	b = strconv.AppendInt(b, ms/1000, 10)
+	b = append(b, '.', byte('0'+ms/100%10), byte('0'+ms/10%10), byte('0'+ms%10))
+	return b
+}
+
+func inZone(t time.Time, utc bool) time.Time {
+	if utc {
+		return t.UTC()
+	}
+	return t.Local()
+}
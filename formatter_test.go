@@ -0,0 +1,146 @@
+package wlog
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setLocal makes time.Local zone for the rest of the test.
+func setLocal(t *testing.T, zone *time.Location) {
+	old := time.Local
+	time.Local = zone
+	t.Cleanup(func() { time.Local = old })
+}
+
+func TestFormatterTime(t *testing.T) {
+	setLocal(t, time.FixedZone("EST", -5*3600))
+	when := time.Date(2026, 3, 1, 12, 30, 45, 123e6, time.FixedZone("CET", 3600))
+	const millis = "2006-01-02 15:04:05.000Z07:00"
+	tests := []struct {
+		name string
+		f    Formatter
+		want string
+	}{
+		{"text local", TextFormatter{}, "2026-03-01 06:30:45 msg"},
+		{"text utc", TextFormatter{UTC: true}, "2026-03-01 11:30:45 msg"},
+		{"text millis local", TextFormatter{TimeFormat: millis}, "2026-03-01 06:30:45.123-05:00 msg"},
+		{"text millis utc", TextFormatter{TimeFormat: millis, UTC: true}, "2026-03-01 11:30:45.123Z msg"},
+		{"json local", JSONFormatter{}, `{"time":"2026-03-01T06:30:45-05:00","level":"info","msg":"msg"}`},
+		{"json utc", JSONFormatter{UTC: true}, `{"time":"2026-03-01T11:30:45Z","level":"info","msg":"msg"}`},
+		{"json millis", JSONFormatter{TimeFormat: millis, UTC: true}, `{"time":"2026-03-01 11:30:45.123Z","level":"info","msg":"msg"}`},
+	}
+	for _, tt := range tests {
+		if got := string(tt.f.Format(when, "msg", LevelInfo)); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTimeFormatConfig(t *testing.T) {
+	bl, dir, _ := newTestFileLogger(t, `"timeformat":"15:04:05.000","daily":false`)
+	bl.SetClock(&fakeClock{now: time.Date(2026, 3, 1, 12, 0, 0, 7e6, time.Local)})
+	bl.Info("msg")
+	bl.Close()
+	if got, want := readFile(t, filepath.Join(dir, "app.log")), "12:00:00.007 [I] msg\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRelativeTime(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+	clock := &fakeClock{now: start}
+	bl, dir, _ := newTestFileLogger(t, fmt.Sprintf(`"timeformat":%q,"daily":false`, TimeRelative))
+	bl.SetClock(clock)
+	bl.SetEpoch(start)
+	for i, d := range []time.Duration{0, 12 * time.Millisecond, 1500 * time.Millisecond, 75 * time.Second} {
+		clock.advance(d)
+		bl.Info("%d", i)
+	}
+	bl.Close()
+	want := "+0.000 [I] 0\n+0.012 [I] 1\n+1.512 [I] 2\n+76.512 [I] 3\n"
+	if got := readFile(t, filepath.Join(dir, "app.log")); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// By default the stamps count from the creation of the logger.
+	bl, dir, _ = newTestFileLogger(t, fmt.Sprintf(`"timeformat":%q,"daily":false`, TimeRelative))
+	for i := 0; i < 3; i++ {
+		time.Sleep(2 * time.Millisecond)
+		bl.Info("%d", i)
+	}
+	bl.Close()
+	last := -1.0
+	for _, line := range strings.Split(strings.TrimSpace(readFile(t, filepath.Join(dir, "app.log"))), "\n") {
+		var secs float64
+		if _, err := fmt.Sscanf(line, "+%f ", &secs); err != nil {
+			t.Fatalf("%q has no relative stamp: %v", line, err)
+		}
+		if secs <= last || secs > 60 {
+			t.Errorf("stamp %q after %.3f, want a later one since the logger was created", line, last)
+		}
+		last = secs
+	}
+}
+
+func TestLineSeparator(t *testing.T) {
+	tests := []struct {
+		config, want string
+	}{
+		{``, "12:00:00 [I] one\n12:00:00 [I] two\n"},
+		{`"lineseparator":"\r\n"`, "12:00:00 [I] one\r\n12:00:00 [I] two\r\n"},
+		{`"lineseparator":"\u001e"`, "12:00:00 [I] one\x1e12:00:00 [I] two\x1e"},
+		{`"lineseparator":"\r\n","format":"json"`, "\n"}, // NDJSON keeps "\n"
+	}
+	for _, tt := range tests {
+		cfg := `"timeformat":"15:04:05","daily":false`
+		if tt.config != "" {
+			cfg += "," + tt.config
+		}
+		bl, dir, _ := newTestFileLogger(t, cfg)
+		bl.SetClock(&fakeClock{now: time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)})
+		bl.Info("one")
+		bl.Info("two")
+		bl.Close()
+		got := readFile(t, filepath.Join(dir, "app.log"))
+		if strings.Contains(tt.config, "json") {
+			if strings.Count(got, "\n") != 2 || strings.Contains(got, "\r") {
+				t.Errorf("%s: got %q, want two NDJSON records", tt.config, got)
+			}
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.config, got, tt.want)
+		}
+	}
+}
+
+// TestPrefix checks that the SetPrefix tag composes with caller info and
+// fields: in brackets in text, as a "logger" field in JSON.
+func TestPrefix(t *testing.T) {
+	tests := []struct {
+		format, want string
+	}{
+		{"text", "12:00:00 [I] [auth] msg k=v\n" +
+			"12:00:00 [W] [auth] [formatter_test.go:%d]caller\n"},
+		{"json", `{"time":"12:00:00","level":"info","msg":"msg","logger":"auth","k":"v"}` + "\n" +
+			`{"time":"12:00:00","level":"warning","msg":"caller","caller":"formatter_test.go:%d","logger":"auth"}` + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			bl, dir, _ := newTestFileLogger(t, fmt.Sprintf(`"format":%q,"timeformat":"15:04:05","daily":false`, tt.format))
+			bl.SetClock(&fakeClock{now: time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)})
+			bl.SetPrefix("auth")
+			bl.With("k", "v").Info("msg")
+			bl.EnableFuncCallDepth(true)
+			bl.Warn("caller")
+			line := thisLine() - 1
+			bl.Close()
+			if got, want := readFile(t, filepath.Join(dir, "app.log")), fmt.Sprintf(tt.want, line); got != want {
+				t.Errorf("got\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
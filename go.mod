@@ -1,3 +1,10 @@
 module github.com/geripper/wlog
 
 go 1.19
+
+require (
+	github.com/go-logr/logr v1.4.4
+	go.opentelemetry.io/otel/trace v1.17.0
+)
+
+require go.opentelemetry.io/otel v1.17.0 // indirect
@@ -0,0 +1,47 @@
+package wlog
+
+import (
+	"time"
+)
+
+// Hook is notified of messages at the levels it returns from Levels.
+type Hook interface {
+	Levels() []int
+	Fire(when time.Time, msg string, level int) error
+}
+
+// AddHook registers h. Hooks fire synchronously, in the order they were
+// added, on the goroutine that logs, before the message reaches the outputs.
+// A hook error is reported on stderr and does not stop the message.
+func (bl *WLogger) AddHook(h Hook) {
+	bl.lock.Lock()
+	defer bl.lock.Unlock()
+	var hooks []Hook
+	if old := bl.hooks.Load(); old != nil {
+		hooks = append(hooks, *old...)
+	}
+	hooks = append(hooks, h)
+	bl.hooks.Store(&hooks)
+}
+
+func (bl *WLogger) fireHooks(r *Record) {
+	hooks := bl.hooks.Load()
+	if hooks == nil {
+		return
+	}
+	var msg string
+	for _, h := range *hooks {
+		for _, l := range h.Levels() {
+			if l != r.Level {
+				continue
+			}
+			if msg == "" {
+				msg = r.text() + fieldsText(r.Fields)
+			}
+			if err := h.Fire(r.Time, msg, r.Level); err != nil {
+				bl.errOut.printf("wlog: hook %T: %v\n", h, err)
+			}
+			break
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package wlog
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordHook records the messages it fires for, in order, tagged with its
+// name.
+type recordHook struct {
+	name   string
+	levels []int
+	err    error
+	mu     *sync.Mutex
+	fired  *[]string
+}
+
+func (h recordHook) Levels() []int { return h.levels }
+
+func (h recordHook) Fire(when time.Time, msg string, level int) error {
+	h.mu.Lock()
+	*h.fired = append(*h.fired, fmt.Sprintf("%s %s %s", h.name, LevelName(level), msg))
+	h.mu.Unlock()
+	return h.err
+}
+
+func TestHooks(t *testing.T) {
+	var mu sync.Mutex
+	var fired []string
+	bl := NewLogger()
+	var out, errOut syncBuffer
+	bl.SetErrorOutput(&errOut)
+	bl.AddWriter(&out, LevelDebug)
+	bl.AddHook(recordHook{"errors", []int{LevelError}, errors.New("hook down"), &mu, &fired})
+	bl.AddHook(recordHook{"all", []int{LevelError, LevelInfo}, nil, &mu, &fired})
+
+	bl.Info("one")
+	bl.Error("two")
+	bl.Debug("three")
+	bl.With("k", 1).Error("four")
+
+	// Hooks get the text as written, level prefix and fields included.
+	want := []string{
+		"all info [I] one",
+		"errors error [E] two",
+		"all error [E] two",
+		"errors error [E] four k=1",
+		"all error [E] four k=1",
+	}
+	if got := strings.Join(fired, "\n"); got != strings.Join(want, "\n") {
+		t.Errorf("fired:\n%s\nwant:\n%s", got, strings.Join(want, "\n"))
+	}
+	// A failing hook is reported and does not stop the message.
+	if n := strings.Count(errOut.String(), "hook down"); n != 2 {
+		t.Errorf("%d hook errors reported, want 2:\n%s", n, errOut.String())
+	}
+	if n := strings.Count(out.String(), "\n"); n != 4 {
+		t.Errorf("%d lines written, want 4", n)
+	}
+}
@@ -0,0 +1,294 @@
+package wlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	httpEncodingNDJSON = "ndjson"
+	httpEncodingJSON   = "json"
+
+	httpMaxRetries = 3
+	httpRetryDelay = 100 * time.Millisecond
+)
+
+// httpWriter buffers lines and POSTs them in batches, when BatchSize lines
+// are pending or every FlushInterval. A failed POST is retried with
+// exponential backoff; once the retries are used up, or if more than
+// MaxPending lines are waiting, lines are dropped and reported on stderr.
+type httpWriter struct {
+	sync.Mutex
+	sendLock  sync.Mutex // held while a batch is posted, keeping batches in order
+	client    *http.Client
+	formatter Formatter
+	pending   [][]byte
+	kick      chan struct{}
+	stopCh    chan struct{}
+	stopOnce  sync.Once
+	done      chan struct{}
+	errOut    errorOutput
+
+	URL           string            `json:"url"`
+	Headers       map[string]string `json:"headers"`
+	Token         string            `json:"token"`    // sent as "Authorization: Bearer <token>"
+	Encoding      string            `json:"encoding"` // "ndjson" or "json" (an array)
+	BatchSize     int               `json:"batchsize"`
+	FlushInterval int               `json:"flushinterval"` // milliseconds
+	Timeout       int               `json:"timeout"`       // milliseconds, 0 means none
+	MaxRetries    int               `json:"maxretries"`
+	MaxPending    int               `json:"maxpending"`
+	Level         int               `json:"level"`
+	formatConfig
+}
+
+func newHTTPWriter() Logger {
+	w := &httpWriter{
+		Encoding:      httpEncodingNDJSON,
+		BatchSize:     100,
+		FlushInterval: 1000,
+		Timeout:       5000,
+		MaxRetries:    httpMaxRetries,
+		MaxPending:    10000,
+		Level:         LevelTrace,
+	}
+	w.Format = FormatJSON
+	return w
+}
+
+func (w *httpWriter) Init(jsonConfig string) error {
+	if len(jsonConfig) > 0 {
+		if err := json.Unmarshal([]byte(jsonConfig), w); err != nil {
+			return err
+		}
+	}
+	return w.setup()
+}
+
+func (w *httpWriter) setup() error {
+	if w.URL == "" {
+		return errors.New("http: must have url")
+	}
+	switch w.Encoding {
+	case httpEncodingNDJSON, httpEncodingJSON:
+	default:
+		return fmt.Errorf("http: unknown encoding %q", w.Encoding)
+	}
+	if w.BatchSize <= 0 {
+		w.BatchSize = 1
+	}
+
+	var err error
+	if w.formatter, err = w.newFormatter(); err != nil {
+		return err
+	}
+	w.client = &http.Client{Timeout: time.Duration(w.Timeout) * time.Millisecond}
+	w.kick = make(chan struct{}, 1)
+	w.stopCh = make(chan struct{})
+	w.done = make(chan struct{})
+	go w.sendLoop()
+	return nil
+}
+
+func (w *httpWriter) setErrorOutput(out io.Writer) {
+	w.errOut.set(out)
+}
+
+func (w *httpWriter) enabled(level int) bool {
+	return !filtered(level, w.Level)
+}
+
+func (w *httpWriter) WriteMsg(when time.Time, msg string, level int) error {
+	return w.writeRecord(newTextRecord(when, msg, level))
+}
+
+func (w *httpWriter) writeRecord(r *Record) error {
+	if filtered(r.Level, w.Level) {
+		return nil
+	}
+
+	line := formatRecord(w.formatter, r)
+	w.Lock()
+	w.pending = append(w.pending, line)
+	dropped := 0
+	if w.MaxPending > 0 && len(w.pending) > w.MaxPending {
+		dropped = len(w.pending) - w.MaxPending
+		w.pending = append(w.pending[:0], w.pending[dropped:]...)
+	}
+	full := len(w.pending) >= w.BatchSize
+	w.Unlock()
+
+	if dropped > 0 {
+		w.errOut.printf("HTTPWriter(%q): dropped %d lines, too many pending\n", w.URL, dropped)
+	}
+	if full {
+		select {
+		case w.kick <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+func (w *httpWriter) sendLoop() {
+	defer close(w.done)
+	interval := time.Duration(w.FlushInterval) * time.Millisecond
+	if interval <= 0 {
+		interval = time.Second
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-w.kick:
+		case <-w.stopCh:
+			return
+		}
+		w.sendPending()
+	}
+}
+
+// sendPending posts the pending lines in batches of at most BatchSize.
+func (w *httpWriter) sendPending() {
+	w.sendLock.Lock()
+	defer w.sendLock.Unlock()
+	for {
+		w.Lock()
+		n := len(w.pending)
+		if n > w.BatchSize {
+			n = w.BatchSize
+		}
+		batch := w.pending[:n:n]
+		w.pending = w.pending[n:]
+		w.Unlock()
+		if n == 0 {
+			return
+		}
+		if err := w.send(batch); err != nil {
+			w.errOut.printf("HTTPWriter(%q): dropped %d lines: %s\n", w.URL, len(batch), err)
+		}
+	}
+}
+
+func (w *httpWriter) send(batch [][]byte) error {
+	body := w.encode(batch)
+	var err error
+	for i := 0; i <= w.MaxRetries; i++ {
+		if i > 0 {
+			time.Sleep(httpRetryDelay << uint(i-1))
+		}
+		var retry bool
+		if retry, err = w.post(body); err == nil || !retry {
+			return err
+		}
+	}
+	return err
+}
+
+// post sends one request, reporting whether a failure is worth retrying.
+func (w *httpWriter) post(body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	if w.Encoding == httpEncodingJSON {
+		req.Header.Set("Content-Type", "application/json")
+	} else {
+		req.Header.Set("Content-Type", "application/x-ndjson")
+	}
+	if w.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+w.Token)
+	}
+	for k, v := range w.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	err = fmt.Errorf("unexpected status %s", resp.Status)
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, err
+}
+
+// HealthCheck sends a HEAD request to URL. Any response short of a server
+// error or a rejected token counts as reachable, as collectors rarely
+// serve HEAD.
+func (w *httpWriter) HealthCheck() error {
+	req, err := http.NewRequest(http.MethodHead, w.URL, nil)
+	if err != nil {
+		return err
+	}
+	if w.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+w.Token)
+	}
+	for k, v := range w.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 500, resp.StatusCode == http.StatusUnauthorized, resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// encode joins the batch as NDJSON or a JSON array. Text lines are sent as
+// JSON strings in an array.
+func (w *httpWriter) encode(batch [][]byte) []byte {
+	var buf bytes.Buffer
+	if w.Encoding == httpEncodingNDJSON {
+		for _, line := range batch {
+			buf.Write(line)
+			buf.WriteByte('\n')
+		}
+		return buf.Bytes()
+	}
+
+	_, isJSON := w.formatter.(JSONFormatter)
+	buf.WriteByte('[')
+	for i, line := range batch {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if isJSON {
+			buf.Write(line)
+		} else {
+			b, _ := json.Marshal(string(line))
+			buf.Write(b)
+		}
+	}
+	buf.WriteByte(']')
+	return buf.Bytes()
+}
+
+// Destroy stops the send loop and posts the lines still pending. Calling it
+// again is a no-op.
+func (w *httpWriter) Destroy() {
+	w.stopOnce.Do(func() { close(w.stopCh) })
+	<-w.done
+	w.sendPending()
+}
+
+func (w *httpWriter) Flush() {
+	w.sendPending()
+}
+
+func init() {
+	Register(AdapterHTTP, newHTTPWriter)
+}
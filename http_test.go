@@ -0,0 +1,141 @@
+package wlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// collector is an httptest handler recording the batches posted to it. The
+// first failures requests are answered with status.
+type collector struct {
+	mu       sync.Mutex
+	batches  []string
+	headers  []http.Header
+	failures int
+	status   int
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.failures > 0 {
+		c.failures--
+		w.WriteHeader(c.status)
+		return
+	}
+	c.batches = append(c.batches, string(body))
+	c.headers = append(c.headers, r.Header.Clone())
+}
+
+func TestHTTPBatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		failures int
+		status   int
+		first    int // first line delivered
+	}{
+		{"ndjson", `"encoding":"ndjson"`, 0, 0, 0},
+		{"json", `"encoding":"json"`, 0, 0, 0},
+		{"text", `"encoding":"json","format":"text"`, 0, 0, 0},
+		{"retry", `"encoding":"ndjson"`, 2, http.StatusServiceUnavailable, 0},
+		// The first batch, lines 0 to 2, is dropped without retrying.
+		{"rejected", `"encoding":"ndjson"`, 1, http.StatusBadRequest, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &collector{failures: tt.failures, status: tt.status}
+			srv := httptest.NewServer(c)
+			defer srv.Close()
+
+			bl := NewLogger()
+			errOut := &syncBuffer{}
+			bl.SetErrorOutput(errOut)
+			cfg := fmt.Sprintf(`{"url":%q,"token":"secret","headers":{"X-App":"test"},"batchsize":3,"flushinterval":60000,%s}`, srv.URL, tt.config)
+			if err := bl.SetLogger(AdapterHTTP, cfg); err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 7; i++ {
+				bl.Info("line %d", i)
+			}
+			bl.Close()
+
+			if got, want := strings.Contains(errOut.String(), "dropped"), tt.first > 0; got != want {
+				t.Errorf("dropped reported %v, want %v:\n%s", got, want, errOut.String())
+			}
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			for i, h := range c.headers {
+				if h.Get("Authorization") != "Bearer secret" || h.Get("X-App") != "test" {
+					t.Errorf("batch %d headers %v", i, h)
+				}
+			}
+			// Batches hold at most batchsize lines and arrive in order.
+			var lines []string
+			for _, b := range c.batches {
+				batch := batchLines(t, tt.config, b)
+				if len(batch) > 3 {
+					t.Errorf("batch of %d lines", len(batch))
+				}
+				lines = append(lines, batch...)
+			}
+			if len(lines) != 7-tt.first {
+				t.Fatalf("delivered %q, want lines %d to 6", lines, tt.first)
+			}
+			for i, line := range lines {
+				if want := fmt.Sprintf("line %d", tt.first+i); !strings.Contains(line, want) {
+					t.Errorf("line %d = %q, want %q", i, line, want)
+				}
+			}
+		})
+	}
+}
+
+func TestHTTPDestroyTwice(t *testing.T) {
+	c := &collector{}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	w := newHTTPWriter().(*httpWriter)
+	if err := w.Init(fmt.Sprintf(`{"url":%q,"flushinterval":60000}`, srv.URL)); err != nil {
+		t.Fatal(err)
+	}
+	w.WriteMsg(time.Now(), "[I] line", LevelInfo)
+	w.Destroy()
+	w.Destroy()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.batches) != 1 {
+		t.Errorf("posted %q, want the line once", c.batches)
+	}
+}
+
+// batchLines splits a posted body into its lines.
+func batchLines(t *testing.T, config, body string) []string {
+	t.Helper()
+	if !strings.Contains(config, `"json"`) {
+		return strings.Split(strings.TrimSuffix(body, "\n"), "\n")
+	}
+	var raw []json.RawMessage
+	if err := json.Unmarshal([]byte(body), &raw); err != nil {
+		t.Fatalf("batch %q: %v", body, err)
+	}
+	var lines []string
+	for _, r := range raw {
+		var s string
+		if json.Unmarshal(r, &s) != nil {
+			s = string(r)
+		}
+		lines = append(lines, s)
+	}
+	return lines
+}
@@ -0,0 +1,61 @@
+package wlog
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Level is a log level. The Level constants are untyped, so they are
+// valid both as a Level and as the plain int most of the API takes.
+type Level int
+
+// String returns the name of l as given by LevelName.
+func (l Level) String() string {
+	return LevelName(int(l))
+}
+
+// Valid reports whether l is one of the levels from LevelEmergency to
+// LevelDebug.
+func (l Level) Valid() bool {
+	return l >= LevelEmergency && l <= LevelDebug
+}
+
+var levelNames = [LevelDebug + 1]string{"emergency", "alert", "critical", "error", "warning", "notice", "info", "debug"}
+
+var levelAliases = map[string]int{
+	"informational": LevelInformational,
+	"warn":          LevelWarn,
+	"trace":         LevelTrace,
+}
+
+// ParseLevel returns the level named s, e.g. "info" or "DEBUG".
+func ParseLevel(s string) (int, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	for level, n := range levelNames {
+		if n == name {
+			return level, nil
+		}
+	}
+	if level, ok := levelAliases[name]; ok {
+		return level, nil
+	}
+	return 0, fmt.Errorf("unknown level %q", s)
+}
+
+// filtered reports whether a message at level is filtered out by a
+// threshold of max. Custom levels above LevelDebug count as LevelDebug, so
+// they are written with the "[?] " prefix wherever debug messages are.
+func filtered(level, max int) bool {
+	if level > LevelDebug {
+		level = LevelDebug
+	}
+	return level > max
+}
+
+// LevelName returns the lower-case name of level, as accepted by ParseLevel.
+func LevelName(level int) string {
+	if level < LevelEmergency || level > LevelDebug {
+		return "unknown"
+	}
+	return levelNames[level]
+}
@@ -0,0 +1,194 @@
+package wlog
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLevelString(t *testing.T) {
+	tests := []struct {
+		level Level
+		name  string
+		valid bool
+	}{
+		{LevelEmergency, "emergency", true},
+		{LevelError, "error", true},
+		{LevelWarn, "warning", true},
+		{LevelInfo, "info", true},
+		{LevelDebug, "debug", true},
+		{LevelTrace, "debug", true},
+		{-2, "unknown", false},
+		{42, "unknown", false},
+	}
+	for _, tt := range tests {
+		if got := tt.level.String(); got != tt.name {
+			t.Errorf("Level(%d).String() = %q, want %q", int(tt.level), got, tt.name)
+		}
+		if got := tt.level.Valid(); got != tt.valid {
+			t.Errorf("Level(%d).Valid() = %v, want %v", int(tt.level), got, tt.valid)
+		}
+		if !tt.valid {
+			continue
+		}
+		if got, err := ParseLevel(strings.ToUpper(tt.name)); err != nil || got != int(tt.level) {
+			t.Errorf("ParseLevel(%q) = %d, %v", tt.name, got, err)
+		}
+	}
+}
+
+// TestSetLevelRange sets levels out of range: they are clamped, and logging
+// at them does not panic.
+func TestSetLevelRange(t *testing.T) {
+	tests := []struct {
+		set, want int
+	}{
+		{42, LevelDebug},
+		{-5, LevelEmergency},
+		{LevelWarning, LevelWarning},
+	}
+	for _, tt := range tests {
+		bl := NewLogger()
+		var out syncBuffer
+		bl.AddWriter(&out, LevelDebug)
+		bl.SetLevel(tt.set)
+		if got := bl.GetLevel(); int(got) != tt.want {
+			t.Errorf("SetLevel(%d): level %v, want %v", tt.set, got, Level(tt.want))
+		}
+		bl.Debug("debug")
+		bl.Emergency("emergency")
+		if got, want := strings.Contains(out.String(), "debug"), tt.want == LevelDebug; got != want {
+			t.Errorf("SetLevel(%d): debug logged %v, want %v", tt.set, got, want)
+		}
+	}
+
+	bl := NewLogger()
+	if err := bl.SetLevelString("loud"); err == nil {
+		t.Error("SetLevelString accepted an unknown level")
+	}
+	if err := bl.SetLevelString("Warn"); err != nil || bl.GetLevel() != LevelWarning {
+		t.Errorf("SetLevelString(Warn): %v, level %v", err, bl.GetLevel())
+	}
+}
+
+// TestUnknownLevel writes at levels outside the known range: nothing may
+// panic, and the message gets the "[?]" prefix. Levels above Debug are
+// filtered as Debug.
+func TestUnknownLevel(t *testing.T) {
+	for _, level := range []int{99, LevelDebug + 1, -3, -100} {
+		bl := NewLogger()
+		var out syncBuffer
+		bl.AddWriter(&out, LevelDebug)
+		if err := bl.WriteMsg(level, "x"); err != nil {
+			t.Errorf("WriteMsg(%d): %v", level, err)
+		}
+		if got := out.String(); !strings.HasSuffix(got, " [?] x\n") {
+			t.Errorf("WriteMsg(%d) wrote %q, want %q", level, got, "[?] x")
+		}
+		if !bl.Enabled(level) {
+			t.Errorf("Enabled(%d) = false", level)
+		}
+	}
+
+	bl := NewLogger()
+	var out syncBuffer
+	bl.AddWriter(&out, LevelDebug)
+	bl.SetLevel(LevelInfo)
+	bl.WriteMsg(99, "x")
+	if got := out.String(); got != "" {
+		t.Errorf("WriteMsg(99) at LevelInfo wrote %q", got)
+	}
+	if bl.Enabled(99) {
+		t.Error("Enabled(99) at LevelInfo")
+	}
+}
+
+// TestWriteMsgLevel checks that WriteMsg honors SetLevel without formatting
+// the filtered message, while Write always passes.
+func TestWriteMsgLevel(t *testing.T) {
+	bl := NewLogger()
+	var out syncBuffer
+	bl.AddWriter(&out, LevelDebug)
+	bl.SetLevel(LevelInfo)
+
+	tests := []struct {
+		write func()
+		want  string // empty when filtered
+	}{
+		{func() { bl.WriteMsg(LevelDebug, "debug %d", 1) }, ""},
+		{func() { bl.WriteMsg(LevelInfo, "info %d", 1) }, "[I] info 1\n"},
+		{func() { bl.WriteMsg(LevelError, "error") }, "[E] error\n"},
+		{func() { bl.Write([]byte("raw\n")) }, " raw\n"},
+	}
+	for i, tt := range tests {
+		before := out.String()
+		tt.write()
+		got := strings.TrimPrefix(out.String(), before)
+		if tt.want == "" && got != "" || tt.want != "" && !strings.HasSuffix(got, tt.want) {
+			t.Errorf("write %d: got %q, want %q", i, got, tt.want)
+		}
+	}
+
+	if n := testing.AllocsPerRun(100, func() { bl.WriteMsg(LevelDebug, "debug %d", 1) }); n != 0 {
+		t.Errorf("filtered WriteMsg allocates %v times", n)
+	}
+}
+
+func TestConfigureFromEnv(t *testing.T) {
+	tests := []struct {
+		env     string
+		set     int // passed to SetLevel first unless -1
+		want    int
+		errored bool
+	}{
+		{"debug", -1, LevelDebug, false},
+		{"WARN", -1, LevelWarning, false},
+		{" error ", -1, LevelError, false},
+		{"", -1, LevelDebug, false},
+		{"loud", -1, LevelDebug, true},
+		{"debug", LevelError, LevelError, false}, // SetLevel wins
+	}
+	for _, tt := range tests {
+		t.Setenv("WLOG_LEVEL", tt.env)
+		bl := NewLogger()
+		var errOut syncBuffer
+		bl.SetErrorOutput(&errOut)
+		if tt.set >= 0 {
+			bl.SetLevel(tt.set)
+		}
+		bl.ConfigureFromEnv()
+		if got := bl.GetLevel(); int(got) != tt.want {
+			t.Errorf("WLOG_LEVEL=%q: level %v, want %v", tt.env, got, Level(tt.want))
+		}
+		if got := strings.Contains(errOut.String(), "WLOG_LEVEL"); got != tt.errored {
+			t.Errorf("WLOG_LEVEL=%q: error reported %v, want %v", tt.env, got, tt.errored)
+		}
+	}
+}
+
+func TestLevelPrefixes(t *testing.T) {
+	bl := NewLogger()
+	bl.SetClock(&fakeClock{now: time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)})
+	var out syncBuffer
+	bl.AddWriter(&out, LevelDebug)
+	bl.SetLevelPrefixes(map[int]string{
+		LevelInfo:  "INFO ",
+		LevelError: "ERROR ",
+		99:         "IGNORED ",
+	})
+	bl.Info("started")
+	bl.Error("failed")
+	bl.Warn("default kept")
+	bl.WriteMsg(-3, "unknown")
+	bl.SetLevelPrefixes(nil)
+	bl.Info("restored")
+
+	want := "2026-03-01 12:00:00 INFO started\n" +
+		"2026-03-01 12:00:00 ERROR failed\n" +
+		"2026-03-01 12:00:00 [W] default kept\n" +
+		"2026-03-01 12:00:00 [?] unknown\n" +
+		"2026-03-01 12:00:00 [I] restored\n"
+	if got := out.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,63 @@
+package wlog
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"sync"
+)
+
+// levelWriter is the io.Writer returned by LevelWriter and LeveledWriter.
+type levelWriter struct {
+	sync.Mutex
+	logger *WLogger
+	level  int  // the level of the lines, or the default with parse
+	parse  bool // a leading level token selects the level of a line
+	skip   int  // frames between the caller to report and Write
+	buf    []byte
+}
+
+// LevelWriter returns an io.Writer logging every line written to it as a
+// message at level. A trailing partial line is held back until its newline
+// is written.
+func (bl *WLogger) LevelWriter(level int) io.Writer {
+	return &levelWriter{logger: bl, level: level}
+}
+
+// StdLogger returns a standard library logger writing its messages to bl
+// at level, e.g. for http.Server.ErrorLog.
+func (bl *WLogger) StdLogger(level int) *log.Logger {
+	// log.Logger.Print* calls Output, which calls Write.
+	return log.New(&levelWriter{logger: bl, level: level, skip: 2}, "", 0)
+}
+
+// Write logs the complete lines of p. It stops at the first line an
+// output fails to write, returning the bytes up to and including it.
+func (w *levelWriter) Write(p []byte) (int, error) {
+	w.Lock()
+	defer w.Unlock()
+	n := 0
+	for {
+		i := bytes.IndexByte(p[n:], '\n')
+		if i < 0 {
+			break
+		}
+		line := append(w.buf, p[n:n+i]...)
+		w.buf = line[:0]
+		n += i + 1
+		if err := w.writeLine(line); err != nil {
+			return n, err
+		}
+	}
+	w.buf = append(w.buf, p[n:]...)
+	return len(p), nil
+}
+
+func (w *levelWriter) writeLine(line []byte) error {
+	level, msg := w.level, string(bytes.TrimSuffix(line, []byte{'\r'}))
+	if w.parse {
+		level, msg = parseLevelPrefix(msg, w.level)
+	}
+	// writeMsg <- writeLine <- Write
+	return w.logger.writeMsg(w.skip+1, level, msg, nil)
+}
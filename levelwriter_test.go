@@ -0,0 +1,107 @@
+package wlog
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// messages returns the lines of out from the level prefix on.
+func messages(out string) []string {
+	var msgs []string
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+		if i := strings.Index(line, "["); i >= 0 {
+			msgs = append(msgs, line[i:])
+		}
+	}
+	return msgs
+}
+
+func TestLevelWriter(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []string
+		want   []string
+	}{
+		{"lines", []string{"one\ntwo\n"}, []string{"[W] one", "[W] two"}},
+		{"partial", []string{"par", "tial", " line\nnext"}, []string{"[W] partial line"}},
+		{"partial completed", []string{"a", "b\n", "c\nd", "\n"}, []string{"[W] ab", "[W] c", "[W] d"}},
+		{"crlf", []string{"dos\r\n"}, []string{"[W] dos"}},
+		{"empty line", []string{"\n"}, []string{"[W] "}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bl := NewLogger()
+			var out syncBuffer
+			bl.AddWriter(&out, LevelDebug)
+			w := bl.LevelWriter(LevelWarning)
+			for _, s := range tt.writes {
+				if n, err := io.WriteString(w, s); err != nil || n != len(s) {
+					t.Fatalf("Write(%q) = %d, %v", s, n, err)
+				}
+			}
+			if got := messages(out.String()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStdLogger(t *testing.T) {
+	bl := NewLogger()
+	var out syncBuffer
+	bl.AddWriter(&out, LevelDebug)
+	bl.EnableFuncCallDepth(true)
+	l := bl.StdLogger(LevelError)
+	l.Printf("http: %s", "bad request")
+	line := thisLine() - 1
+	l.Print("multi\nline")
+
+	want := []string{
+		fmt.Sprintf("[E] [levelwriter_test.go:%d]http: bad request", line),
+		fmt.Sprintf("[E] [levelwriter_test.go:%d]multi", line+2),
+		fmt.Sprintf("[E] [levelwriter_test.go:%d]line", line+2),
+	}
+	if got := messages(out.String()); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// TestLevelWriterError makes the output fail: Write stops at the failed
+// line and counts the bytes up to its newline, leaving the rest unwritten.
+func TestLevelWriterError(t *testing.T) {
+	errBroken := errors.New("broken pipe")
+	for _, leveled := range []bool{false, true} {
+		t.Run(fmt.Sprintf("leveled=%v", leveled), func(t *testing.T) {
+			bl := NewLogger()
+			bl.SetErrorOutput(io.Discard)
+			bl.AddWriter(failWriter{errBroken}, LevelDebug)
+			w := bl.LevelWriter(LevelInfo)
+			if leveled {
+				w = bl.LeveledWriter(LevelInfo)
+			}
+			if n, err := io.WriteString(w, "one\ntwo\n"); n != len("one\n") || !errors.Is(err, errBroken) {
+				t.Errorf("Write = %d, %v, want %d, %v", n, err, len("one\n"), errBroken)
+			}
+		})
+	}
+}
+
+// TestLeveledWriterPartial checks that LeveledWriter, like LevelWriter,
+// holds back partial lines and drops the CR of CRLF.
+func TestLeveledWriterPartial(t *testing.T) {
+	bl := NewLogger()
+	var out syncBuffer
+	bl.AddWriter(&out, LevelDebug)
+	w := bl.LeveledWriter(LevelInfo)
+	for _, s := range []string{"[ER", "ROR] fa", "iled\r\nWARN: lo", "w\n", "tail"} {
+		io.WriteString(w, s)
+	}
+	want := []string{"[E] failed", "[W] low"}
+	if got := messages(out.String()); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
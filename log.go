@@ -1,14 +1,16 @@
 package wlog
 
 import (
-	// "encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"os"
-	"path"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -25,7 +27,13 @@ const (
 
 const (
 	levelLoggerImpl = -1
+	AdapterConsole  = "console"
+	AdapterConn     = "conn"
 	AdapterFile     = "file"
+	AdapterHTTP     = "http"
+	AdapterMemory   = "memory"
+	AdapterSyslog   = "syslog"
+	AdapterWriter   = "writer" // outputs added by AddWriter
 )
 
 const (
@@ -34,6 +42,11 @@ const (
 	LevelWarn  = LevelWarning
 )
 
+// Logger is implemented by the outputs. WriteMsg is called from several
+// goroutines at once, so an implementation must serialize its writes and
+// hand each formatted line, newline included, to its writer in a single
+// Write call. Lines from concurrent callers then never interleave, even
+// when other processes append to the same file or socket.
 type Logger interface {
 	Init(config string) error
 	WriteMsg(when time.Time, msg string, level int) error
@@ -41,108 +54,536 @@ type Logger interface {
 	Flush()
 }
 
+// HealthChecker is implemented by outputs that can tell whether they are
+// able to write, for Check.
+type HealthChecker interface {
+	HealthCheck() error
+}
+
 var levelPrefix = [LevelDebug + 1]string{"[M] ", "[A] ", "[C] ", "[E] ", "[W] ", "[N] ", "[I] ", "[D] "}
 
+// SetLevelPrefixes replaces the "[I] " style prefixes of the given levels,
+// e.g. with "INFO ". Levels not in prefixes keep the default; a nil map
+// restores all of them.
+func (bl *WLogger) SetLevelPrefixes(prefixes map[int]string) {
+	if prefixes == nil {
+		bl.prefixes.Store(nil)
+		return
+	}
+	t := levelPrefix
+	for level, prefix := range prefixes {
+		if Level(level).Valid() {
+			t[level] = prefix
+		}
+	}
+	bl.prefixes.Store(&t)
+}
+
+// SetEpoch sets the time TimeRelative stamps count from, the creation of
+// the logger by default.
+func (bl *WLogger) SetEpoch(t time.Time) {
+	bl.epoch.Store(&t)
+}
+
+// SetPrefix tags every message of the logger with name, written after the
+// level as "[name] " in text and as a "logger" key in JSON. An empty name
+// removes the tag.
+func (bl *WLogger) SetPrefix(name string) {
+	if name == "" {
+		bl.prefix.Store(nil)
+		return
+	}
+	bl.prefix.Store(&name)
+}
+
+// prefixOf returns the prefix of messages at level, "[?] " for levels
+// other than the defined ones.
+func prefixOf(level int) string {
+	if level < LevelEmergency || level > LevelDebug {
+		return "[?] "
+	}
+	return levelPrefix[level]
+}
+
 type WLogger struct {
 	lock                sync.Mutex
-	level               int
-	init                bool
-	enableFuncCallDepth bool
-	loggerFuncCallDepth int
+	closeLock           sync.RWMutex // held for reading by writes and flushes in flight
+	closed              bool
+	level               atomic.Int32
+	levelSet            atomic.Bool // SetLevel was called
+	nop                 bool        // made by NewNop, the level stays below all messages
+	maxMessageBytes     atomic.Int64
+	init                atomic.Bool // set once an output is in place, read without the lock
+	initErr             error
+	defaultAdapter      string
+	defaultConfigs      []string
+	enableFuncCallDepth atomic.Bool
+	enableFuncName      atomic.Bool
+	callerMinLevel      atomic.Int32
+	loggerFuncCallDepth atomic.Int32
+	callerTrim          atomic.Int32
+	callerRoot          atomic.Pointer[string]
 	asynchronous        bool
 	msgChanLen          int64
-	msgChan             chan *logMsg
-	signalChan          chan string
-	wg                  sync.WaitGroup
-	outputs             *nameLogger
+	msgChan             chan *Record
+	msgPool             sync.Pool
+	batch               atomic.Pointer[batchConfig]
+	overflowPolicy      atomic.Int32
+	written             atomic.Int64
+	dropped             atomic.Int64
+	afterClose          atomic.Int64 // messages logged after Close
+	warnAfterClose      atomic.Bool
+	closeWarned         atomic.Bool
+	errors              atomic.Int64
+	onError             atomic.Pointer[func(error)]
+	onRotate            func(oldName, newName string)
+	errOut              errorOutput
+	samplers            [LevelDebug + 1]atomic.Pointer[sampler]
+	limiters            [LevelDebug + 1]atomic.Pointer[rateLimiter]
+	dedup               atomic.Pointer[deduper]
+	prefixes            atomic.Pointer[[LevelDebug + 1]string]
+	prefix              atomic.Pointer[string]
+	epoch               atomic.Pointer[time.Time]
+	hooks               atomic.Pointer[[]Hook]
+	clock               clockRef
+	signalChan          chan logSignal
+	signalLock          sync.Mutex
+	workers             int
+	outputs             atomic.Pointer[[]*nameLogger] // replaced whole under lock, read without it
+}
+
+const (
+	defaultAsyncMsgLen = 1e3
+	// maxAsyncMsgLen caps the async channel, which is allocated up front.
+	maxAsyncMsgLen = 1 << 20
+)
+
+// chanLen returns the async channel length given as the first of lens, or
+// def if there is none or it is not positive. Lengths above maxAsyncMsgLen
+// are capped with a warning.
+func (bl *WLogger) chanLen(lens []int64, def int64) int64 {
+	if len(lens) == 0 || lens[0] <= 0 {
+		return def
+	}
+	if lens[0] > maxAsyncMsgLen {
+		bl.errOut.printf("wlog: channel length %d capped at %d\n", lens[0], maxAsyncMsgLen)
+		return maxAsyncMsgLen
+	}
+	return lens[0]
 }
 
-const defaultAsyncMsgLen = 1e3
+// Policy decides what an async logger does with a message when its channel
+// is full.
+type Policy int
+
+const (
+	Block      Policy = iota // wait for room in the channel
+	Drop                     // discard the new message
+	DropOldest               // discard the oldest queued message
+)
+
+var adapters = make(map[string]func() Logger)
+
+// Register makes an adapter available by name to SetLogger. It is meant to be
+// called from init and panics if newFunc is nil or name is already taken.
+func Register(name string, newFunc func() Logger) {
+	if newFunc == nil {
+		panic("wlog: Register adapter is nil")
+	}
+	if _, dup := adapters[name]; dup {
+		panic("wlog: Register called twice for adapter " + name)
+	}
+	adapters[name] = newFunc
+}
 
 type nameLogger struct {
 	Logger
-	name string
+	name     string // the adapter name, or the one given to SetNamedLogger
+	adapter  string
+	minLevel int
+	maxLevel int
+
+	// config is what SetLogger, SetLoggerMap or SetFileLogger was called
+	// with, kept so Reset can set the output up again. Outputs added
+	// otherwise have configured unset.
+	config     interface{}
+	configured bool
+}
+
+// levelRange is the "minlevel"/"maxlevel" band an output accepts, read from
+// the same config as the adapter itself.
+type levelRange struct {
+	MinLevel int `json:"minlevel"`
+	MaxLevel int `json:"maxlevel"`
+}
+
+func newNameLogger(name string, lg Logger) *nameLogger {
+	return &nameLogger{Logger: lg, name: name, minLevel: math.MinInt, maxLevel: math.MaxInt}
 }
 
-type logMsg struct {
-	level int
-	msg   string
-	when  time.Time
+// accepts reports whether level falls in the output's band. Raw writes
+// through Write are not leveled and always pass.
+func (l *nameLogger) accepts(level int) bool {
+	return level == levelLoggerImpl || (level >= l.minLevel && level <= l.maxLevel)
 }
 
-var logMsgPool *sync.Pool
+// logSignal asks an async worker to "flush" or "close". The worker drains
+// the channel, marks drained and waits for done before it goes on, so that
+// each of the signals sent for one request reaches a different worker.
+type logSignal struct {
+	op      string
+	drained *sync.WaitGroup
+	done    chan struct{}
+}
 
 func NewLogger(channelLens ...int64) *WLogger {
 	bl := new(WLogger)
-	bl.level = LevelDebug
-	bl.loggerFuncCallDepth = 2
-	bl.msgChanLen = append(channelLens, 0)[0]
-	if bl.msgChanLen <= 0 {
-		bl.msgChanLen = defaultAsyncMsgLen
-	}
-	bl.signalChan = make(chan string, 1)
-	// bl.SetLogger(AdapterFile)
+	bl.level.Store(LevelDebug)
+	bl.loggerFuncCallDepth.Store(2)
+	bl.callerTrim.Store(1)
+	bl.callerMinLevel.Store(LevelDebug)
+	epoch := time.Now()
+	bl.epoch.Store(&epoch)
+	bl.msgChanLen = bl.chanLen(channelLens, defaultAsyncMsgLen)
+	bl.signalChan = make(chan logSignal, 1)
+	bl.msgPool.New = func() interface{} {
+		return &Record{}
+	}
+	bl.defaultAdapter = AdapterConsole
+	return bl
+}
+
+// NewNop returns a logger without outputs whose level is below every
+// message, so each call returns after one level check, allocating nothing.
+// It is meant for code taking a *WLogger when logging is to be off.
+// SetLevel has no effect on it.
+func NewNop() *WLogger {
+	bl := NewLogger()
+	bl.nop = true
+	bl.level.Store(levelLoggerImpl - 1)
+	bl.defaultAdapter = ""
+	bl.init.Store(true)
 	return bl
 }
 
+// Async switches to writing from background goroutines. msgLen optionally
+// gives the channel length, at most 1<<20, and, as a second value, the
+// number of worker goroutines, 1 by default. With more than one worker
+// messages may be written out of order; it only pays off with adapters
+// that lock internally, as all built-in ones do.
+//
+// Async waits for sync writes in flight, so with one worker every message
+// is written in the order it was logged, across the switch too.
 func (bl *WLogger) Async(msgLen ...int64) *WLogger {
+	bl.closeLock.Lock()
+	defer bl.closeLock.Unlock()
 	bl.lock.Lock()
 	defer bl.lock.Unlock()
-	if bl.asynchronous {
+	if bl.asynchronous || bl.closed {
 		return bl
 	}
-	bl.asynchronous = true
-	if len(msgLen) > 0 && msgLen[0] > 0 {
-		bl.msgChanLen = msgLen[0]
+	bl.msgChanLen = bl.chanLen(msgLen, bl.msgChanLen)
+	bl.workers = 1
+	if len(msgLen) > 1 && msgLen[1] > 1 {
+		bl.workers = int(msgLen[1])
 	}
-	bl.msgChan = make(chan *logMsg, bl.msgChanLen)
-	logMsgPool = &sync.Pool{
-		New: func() interface{} {
-			return &logMsg{}
-		},
+	bl.msgChan = make(chan *Record, bl.msgChanLen)
+	for i := 0; i < bl.workers; i++ {
+		go bl.startLogger()
 	}
-	bl.wg.Add(1)
-	go bl.startLogger()
+	bl.asynchronous = true
 	return bl
 }
 
-func (bl *WLogger) setLogger(adapterName string, configs ...string) error {
-	config := append(configs, "{}")[0]
+// setLogger adds an output of adapterName under name, set up from config,
+// which is anything initLogger takes.
+func (bl *WLogger) setLogger(name, adapterName string, config interface{}) error {
+	for _, l := range bl.loadOutputs() {
+		if l.name == name {
+			return fmt.Errorf("duplicate adapter %q (you have set this logger before)", name)
+		}
+	}
 
-	lg := newFileWriter()
-	err := lg.Init(config)
+	newLogger, ok := adapters[adapterName]
+	if !ok {
+		err := fmt.Errorf("unknown adapter %q", adapterName)
+		bl.errOut.printf("logs.SetLogger:%s\n", err)
+		return err
+	}
+
+	lr, err := readLevelRange(config)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "logs.SetLogger:"+err.Error())
+		bl.errOut.printf("logs.SetLogger:%s\n", err)
 		return err
 	}
 
-	bl.outputs = &nameLogger{name: adapterName, Logger: lg}
+	lg := newLogger()
+	if w, ok := lg.(*fileLogWriter); ok {
+		w.onRotate = bl.onRotate
+	}
+	if w := bl.errOut.p.Load(); w != nil {
+		if s, ok := lg.(errorOutputSetter); ok {
+			s.setErrorOutput(*w)
+		}
+	}
+	if c := bl.clock.p.Load(); c != nil {
+		if cs, ok := lg.(clockSetter); ok {
+			cs.setClock(*c)
+		}
+	}
+	if err := initLogger(lg, config); err != nil {
+		bl.errOut.printf("logs.SetLogger:%s\n", err)
+		return err
+	}
+
+	nl := newNameLogger(name, lg)
+	nl.adapter = adapterName
+	nl.minLevel, nl.maxLevel = lr.MinLevel, lr.MaxLevel
+	nl.config, nl.configured = config, true
+	bl.addOutput(nl)
 	return nil
 }
 
+// loadOutputs returns the current outputs. The slice is never modified in
+// place, so it can be ranged over while outputs are added or removed.
+func (bl *WLogger) loadOutputs() []*nameLogger {
+	if p := bl.outputs.Load(); p != nil {
+		return *p
+	}
+	return nil
+}
+
+// storeOutputs publishes outputs, which must not be modified afterwards.
+// The caller must hold the lock.
+func (bl *WLogger) storeOutputs(outputs []*nameLogger) {
+	bl.outputs.Store(&outputs)
+}
+
+// addOutput publishes a copy of the outputs with l added. The caller must
+// hold the lock.
+func (bl *WLogger) addOutput(l *nameLogger) {
+	outputs := bl.loadOutputs()
+	bl.storeOutputs(append(outputs[:len(outputs):len(outputs)], l))
+}
+
+// SetLogger adds an output. Each output filters messages by its own "level"
+// config on top of the logger level set by SetLevel, so a message is written
+// to an output only if both let it through.
+//
+// Every adapter config also takes "minlevel" and "maxlevel", the numeric
+// band of levels the output accepts, so messages can be routed by level.
+// As lower numbers are more severe, {"maxlevel":3} takes Error and above
+// and {"minlevel":4} takes Warning and below.
 func (bl *WLogger) SetLogger(adapterName string, configs ...string) error {
+	return bl.addLogger(adapterName, adapterName, append(configs, "{}")[0])
+}
+
+// SetNamedLogger is SetLogger adding the output under name instead of the
+// adapter name, so that one adapter can be added several times, e.g. two
+// file outputs writing text to app.log and JSON to app.json. DelNamedLogger
+// and FileStats take the name.
+func (bl *WLogger) SetNamedLogger(name, adapterName string, configs ...string) error {
+	return bl.addLogger(name, adapterName, append(configs, "{}")[0])
+}
+
+// addLogger is setLogger for the exported functions, which also mark the
+// logger as set up.
+func (bl *WLogger) addLogger(name, adapterName string, config interface{}) error {
 	bl.lock.Lock()
 	defer bl.lock.Unlock()
-	if !bl.init {
-		bl.init = true
+	err := bl.setLogger(name, adapterName, config)
+	if err == nil {
+		bl.initErr = nil
 	}
-	return bl.setLogger(adapterName, configs...)
+	bl.init.Store(true)
+	return err
+}
+
+// lazyInit sets up the default output the first time the logger is used
+// without SetLogger having been called, and keeps its error.
+func (bl *WLogger) lazyInit() error {
+	bl.lock.Lock()
+	defer bl.lock.Unlock()
+	if !bl.init.Load() {
+		if bl.defaultAdapter == "" {
+			bl.initErr = errors.New("no adapter configured")
+		} else {
+			bl.initErr = bl.setLogger(bl.defaultAdapter, bl.defaultAdapter, append(bl.defaultConfigs, "{}")[0])
+		}
+		bl.init.Store(true)
+	}
+	return bl.initErr
+}
+
+// SetDefaultLogger chooses the output set up when the logger is used before
+// any SetLogger call, AdapterConsole unless changed. An empty adapterName
+// sets up none, making writes fail until SetLogger is called.
+func (bl *WLogger) SetDefaultLogger(adapterName string, configs ...string) {
+	bl.lock.Lock()
+	defer bl.lock.Unlock()
+	bl.defaultAdapter = adapterName
+	bl.defaultConfigs = configs
+}
+
+// Init sets up the default output if SetLogger has not been called and
+// returns an error if the logger has no working output, so configuration
+// problems can be caught at startup rather than on the first message.
+func (bl *WLogger) Init() error {
+	if err := bl.lazyInit(); err != nil {
+		return err
+	}
+	bl.lock.Lock()
+	defer bl.lock.Unlock()
+	if len(bl.loadOutputs()) == 0 {
+		return errors.New("no adapter configured")
+	}
+	return nil
 }
 
 // DelLogger 移除logger
 func (bl *WLogger) DelLogger() error {
 	bl.lock.Lock()
 	defer bl.lock.Unlock()
-	bl.outputs.Destroy()
-	bl.outputs = nil
+	outputs := bl.loadOutputs()
+	bl.storeOutputs(nil)
+	for _, l := range outputs {
+		l.Destroy()
+	}
 	return nil
 }
 
-func (bl *WLogger) writeToLoggers(when time.Time, msg string, level int) {
-	err := bl.outputs.WriteMsg(when, msg, level)
+// DelNamedLogger removes and destroys the output added under name, the
+// adapter name unless it was added with SetNamedLogger.
+func (bl *WLogger) DelNamedLogger(name string) error {
+	bl.lock.Lock()
+	defer bl.lock.Unlock()
+	old := bl.loadOutputs()
+	outputs := make([]*nameLogger, 0, len(old))
+	var removed []*nameLogger
+	for _, l := range old {
+		if l.name == name {
+			removed = append(removed, l)
+		} else {
+			outputs = append(outputs, l)
+		}
+	}
+	if len(removed) == 0 {
+		return fmt.Errorf("unknown adapter %q (forgotten SetLogger?)", name)
+	}
+	// Writes still ranging over the old slice may reach a removed output
+	// after this; the adapters drop writes once destroyed.
+	bl.storeOutputs(outputs)
+	for _, l := range removed {
+		l.Destroy()
+	}
+	return nil
+}
+
+// writeToLoggers writes to every output and returns the first error.
+// Only records accepted by at least one output count as written.
+func (bl *WLogger) writeToLoggers(r *Record) error {
+	var firstErr error
+	received := false
+	for _, l := range bl.loadOutputs() {
+		if !l.accepts(r.Level) {
+			continue
+		}
+		received = true
+		if err := bl.writeToLogger(l, r); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if received {
+		bl.written.Add(1)
+	}
+	return firstErr
+}
+
+func (bl *WLogger) writeToLogger(l *nameLogger, r *Record) error {
+	if !l.accepts(r.Level) {
+		return nil
+	}
+	var err error
+	if rl, ok := l.Logger.(RecordLogger); ok {
+		err = rl.WriteRecord(*r)
+	} else if rl, ok := l.Logger.(recordLogger); ok {
+		err = rl.writeRecord(r)
+	} else {
+		err = l.WriteMsg(r.Time, r.text()+fieldsText(r.Fields), r.Level)
+	}
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "unable to writeMsg to adapter:%v,error:%v\n", bl.outputs.name, err)
+		bl.adapterError(l, err)
 	}
+	return err
+}
+
+func (bl *WLogger) adapterError(l *nameLogger, err error) {
+	bl.errors.Add(1)
+	if f := bl.onError.Load(); f != nil {
+		(*f)(fmt.Errorf("adapter %s: %w", l.name, err))
+		return
+	}
+	bl.errOut.printf("unable to writeMsg to adapter:%v,error:%v\n", l.name, err)
+}
+
+// OnError sets a function called with every failed adapter write or
+// flush, in place of the default report on stderr. In async mode it runs
+// on the logging goroutine. A nil f restores the report.
+func (bl *WLogger) OnError(f func(error)) {
+	if f == nil {
+		bl.onError.Store(nil)
+		return
+	}
+	bl.onError.Store(&f)
+}
+
+// levelEnabler is implemented by adapters with their own level filter, so
+// Enabled can tell whether they would write a message.
+type levelEnabler interface {
+	enabled(level int) bool
+}
+
+// batchLogger is implemented by adapters that can write several async
+// messages at once.
+type batchLogger interface {
+	writeBatch(rs []*Record) error
+}
+
+func (bl *WLogger) writeBatchToLoggers(msgs []*Record) {
+	outputs := bl.loadOutputs()
+	for _, r := range msgs {
+		for _, l := range outputs {
+			if l.accepts(r.Level) {
+				bl.written.Add(1)
+				break
+			}
+		}
+	}
+	for _, l := range outputs {
+		if b, ok := l.Logger.(batchLogger); ok {
+			if err := b.writeBatch(l.inRange(msgs)); err != nil {
+				bl.adapterError(l, err)
+			}
+			continue
+		}
+		for _, r := range msgs {
+			bl.writeToLogger(l, r)
+		}
+	}
+}
+
+// inRange returns the messages of msgs the output accepts.
+func (l *nameLogger) inRange(msgs []*Record) []*Record {
+	if l.minLevel == math.MinInt && l.maxLevel == math.MaxInt {
+		return msgs
+	}
+	in := make([]*Record, 0, len(msgs))
+	for _, r := range msgs {
+		if l.accepts(r.Level) {
+			in = append(in, r)
+		}
+	}
+	return in
 }
 
 func (bl *WLogger) Write(p []byte) (int, error) {
@@ -153,7 +594,7 @@ func (bl *WLogger) Write(p []byte) (int, error) {
 		p = p[:len(p)-1]
 	}
 
-	err := bl.WriteMsg(levelLoggerImpl, string(p))
+	err := bl.writeMsg(0, levelLoggerImpl, string(p), nil)
 	if err == nil {
 		return len(p), nil
 	}
@@ -161,206 +602,749 @@ func (bl *WLogger) Write(p []byte) (int, error) {
 	return 0, err
 }
 
+// WriteMsg formats msg with v like fmt.Sprintf and writes it at logLevel.
+// msg is a format even without v, so a literal % must be written %%; the
+// ln methods, such as Infoln, take text as is. Messages above the level
+// set with SetLevel are dropped; custom levels above LevelDebug count as
+// LevelDebug and are written with a "[?] " prefix. In sync mode it returns
+// the first error reported by an output.
 func (bl *WLogger) WriteMsg(logLevel int, msg string, v ...interface{}) error {
-	if !bl.init {
-		bl.lock.Lock()
-		bl.setLogger(AdapterFile)
-		bl.lock.Unlock()
+	if filtered(logLevel, int(bl.level.Load())) {
+		return nil
 	}
+	return bl.writeMsg(0, logLevel, sprintf(msg, v...), nil)
+}
 
-	length := len(v)
-	if length > 0 {
-		if strings.Contains(msg, "%") {
-			msg = fmt.Sprintf(msg, v...)
-		} else {
-			msg += fmt.Sprint(v...)
+// writeMsg does the work of WriteMsg. skip is 0 when writeMsg is called
+// directly from an exported method, plus one per extra frame in between;
+// it is added to loggerFuncCallDepth for runtime.Caller.
+func (bl *WLogger) writeMsg(skip int, logLevel int, msg string, fields []Field) error {
+	return bl.writeMsgAt(time.Time{}, 0, skip+1, logLevel, msg, fields)
+}
+
+// writeMsgAt is writeMsg for messages that carry their time and call site,
+// such as slog records. A zero when is taken from the clock, and a zero pc
+// has the caller looked up skip frames up as writeMsg does.
+func (bl *WLogger) writeMsgAt(when time.Time, pc uintptr, skip int, logLevel int, msg string, fields []Field) error {
+	if filtered(logLevel, int(bl.level.Load())) {
+		return nil
+	}
+	bl.closeLock.RLock()
+	defer bl.closeLock.RUnlock()
+	if bl.closed {
+		bl.droppedAfterClose()
+		return nil
+	}
+
+	// Without outputs the message would be lost; lazyInit keeps returning
+	// the error of a default output that failed to set up.
+	if !bl.init.Load() || len(bl.loadOutputs()) == 0 {
+		if err := bl.lazyInit(); err != nil {
+			return err
 		}
 	}
-	when := time.Now().Local()
-	if bl.enableFuncCallDepth {
-		_, file, line, ok := runtime.Caller(bl.loggerFuncCallDepth)
-		if !ok {
-			file = "???"
-			line = 0
+
+	if when.IsZero() {
+		when = bl.clock.now()
+	}
+	when = when.Local()
+	if !bl.sampled(logLevel, msg, when) || !bl.rateLimit(logLevel, when) || !bl.deduped(logLevel, msg, fields, when) {
+		return nil
+	}
+
+	msg, fields = bl.truncate(msg, fields)
+	r := bl.getRecord(when, logLevel, msg)
+	r.Fields = fields
+	if bl.enableFuncCallDepth.Load() && logLevel <= int(bl.callerMinLevel.Load()) {
+		var file, fn string
+		var line int
+		if pc == 0 {
+			var ok bool
+			pc, file, line, ok = runtime.Caller(int(bl.loggerFuncCallDepth.Load()) + skip)
+			if !ok {
+				file = "???"
+				line = 0
+			}
+			fn = funcName(pc)
+		} else {
+			frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+			file, line, fn = frame.File, frame.Line, shortFuncName(frame.Function)
+		}
+		r.Caller = bl.callerFile(file) + ":" + strconv.Itoa(line)
+		if bl.enableFuncName.Load() {
+			r.Caller += " " + fn
 		}
-		_, filename := path.Split(file)
-		msg = "[" + filename + ":" + strconv.FormatInt(int64(line), 10) + "]" + msg
 	}
+	return bl.dispatch(r)
+}
 
-	if logLevel == levelLoggerImpl {
-		logLevel = LevelEmergency
-	} else {
-		msg = levelPrefix[logLevel] + msg
+// getRecord takes a Record from msgPool for a message at level.
+func (bl *WLogger) getRecord(when time.Time, level int, msg string) *Record {
+	r := bl.msgPool.Get().(*Record)
+	r.Time, r.Level, r.Msg = when, level, msg
+	if level == levelLoggerImpl {
+		r.Level = LevelEmergency
+		r.raw = true
 	}
+	r.prefixes = bl.prefixes.Load()
+	r.epoch = bl.epoch.Load()
+	if p := bl.prefix.Load(); p != nil {
+		r.Prefix = *p
+	}
+	return r
+}
 
+// dispatch queues a record taken from msgPool in async mode, or writes it
+// and puts it back.
+func (bl *WLogger) dispatch(r *Record) error {
+	bl.fireHooks(r)
 	if bl.asynchronous {
-		lm := logMsgPool.Get().(*logMsg)
-		lm.level = logLevel
-		lm.msg = msg
-		lm.when = when
-		bl.msgChan <- lm
-	} else {
-		bl.writeToLoggers(when, msg, logLevel)
+		bl.enqueue(r)
+		return nil
 	}
+	err := bl.writeToLoggers(r)
+	bl.putRecord(r)
+	return err
+}
 
-	return nil
+func (bl *WLogger) putRecord(r *Record) {
+	*r = Record{}
+	bl.msgPool.Put(r)
+}
+
+// Enabled reports whether a message at level would reach at least one
+// output, so callers can skip building messages that would be dropped.
+func (bl *WLogger) Enabled(level int) bool {
+	if filtered(level, int(bl.level.Load())) {
+		return false
+	}
+	if !bl.init.Load() {
+		return true
+	}
+	for _, l := range bl.loadOutputs() {
+		if !l.accepts(level) {
+			continue
+		}
+		if le, ok := l.Logger.(levelEnabler); ok && !le.enabled(level) {
+			continue
+		}
+		return true
+	}
+	return false
 }
 
+// SetLevel sets the logger level checked by the level methods before a
+// message is built. Outputs may filter further with their own level.
+// Levels outside LevelEmergency to LevelDebug are clamped to the nearest.
 func (bl *WLogger) SetLevel(l int) {
-	bl.level = l
+	if bl.nop {
+		return
+	}
+	if l < LevelEmergency {
+		l = LevelEmergency
+	} else if l > LevelDebug {
+		l = LevelDebug
+	}
+	bl.level.Store(int32(l))
+	bl.levelSet.Store(true)
+}
+
+// GetLevel returns the logger level.
+func (bl *WLogger) GetLevel() Level {
+	return Level(bl.level.Load())
+}
+
+// SetLevelString sets the logger level from a name accepted by ParseLevel.
+func (bl *WLogger) SetLevelString(s string) error {
+	level, err := ParseLevel(s)
+	if err != nil {
+		return err
+	}
+	bl.SetLevel(level)
+	return nil
+}
+
+// ConfigureFromEnv sets the logger level from the WLOG_LEVEL environment
+// variable, unless it was already set with SetLevel. An unknown value is
+// reported on stderr and leaves the level unchanged.
+func (bl *WLogger) ConfigureFromEnv() {
+	s := os.Getenv("WLOG_LEVEL")
+	if s == "" || bl.levelSet.Load() || bl.nop {
+		return
+	}
+	level, err := ParseLevel(s)
+	if err != nil {
+		bl.errOut.printf("WLOG_LEVEL: %s\n", err)
+		return
+	}
+	bl.level.Store(int32(level))
 }
 
 func (bl *WLogger) SetLogFuncCallDepth(d int) {
-	bl.loggerFuncCallDepth = d
+	bl.loggerFuncCallDepth.Store(int32(d))
 }
 
 func (bl *WLogger) GetLogFuncCallDepth() int {
-	return bl.loggerFuncCallDepth
+	return int(bl.loggerFuncCallDepth.Load())
 }
 
 func (bl *WLogger) EnableFuncCallDepth(b bool) {
-	bl.enableFuncCallDepth = b
+	bl.enableFuncCallDepth.Store(b)
+}
+
+// EnableFuncName adds the calling function, e.g. "pkg.ServeHTTP", to the
+// caller info written when EnableFuncCallDepth is on.
+func (bl *WLogger) EnableFuncName(b bool) {
+	bl.enableFuncName.Store(b)
+}
+
+// SetCallerMinLevel limits the caller info written when EnableFuncCallDepth
+// is on to messages of level and above, e.g. LevelWarning, sparing the
+// runtime.Caller call for the others. The default is LevelDebug, all
+// levels.
+func (bl *WLogger) SetCallerMinLevel(level int) {
+	bl.callerMinLevel.Store(int32(level))
+}
+
+// SetCallerTrim keeps the last segments path elements of the caller's file,
+// e.g. "handler/user.go" for 2. The default is 1, the file name alone; 0
+// keeps the full path.
+func (bl *WLogger) SetCallerTrim(segments int) {
+	bl.callerTrim.Store(int32(segments))
+}
+
+// SetCallerRoot writes the caller's file relative to root, typically the
+// project directory, when it lies below it. Other files are trimmed as set
+// with SetCallerTrim. An empty root turns it off.
+func (bl *WLogger) SetCallerRoot(root string) {
+	if root == "" {
+		bl.callerRoot.Store(nil)
+		return
+	}
+	root = strings.TrimSuffix(filepath.ToSlash(root), "/") + "/"
+	bl.callerRoot.Store(&root)
+}
+
+// callerFile shortens file, as returned by runtime.Caller, for the caller
+// info.
+func (bl *WLogger) callerFile(file string) string {
+	if root := bl.callerRoot.Load(); root != nil && strings.HasPrefix(file, *root) {
+		return file[len(*root):]
+	}
+	n := int(bl.callerTrim.Load())
+	if n <= 0 {
+		return file
+	}
+	for i := len(file) - 1; i >= 0; i-- {
+		if file[i] == '/' {
+			n--
+			if n == 0 {
+				return file[i+1:]
+			}
+		}
+	}
+	return file
+}
+
+// funcName returns the package-qualified name of the function containing pc,
+// without the import path.
+func funcName(pc uintptr) string {
+	fn := runtime.FuncForPC(pc)
+	if fn == nil {
+		return "???"
+	}
+	return shortFuncName(fn.Name())
+}
+
+// shortFuncName trims the package path of a function name, leaving e.g.
+// "pkg.ServeHTTP".
+func shortFuncName(name string) string {
+	if name == "" {
+		return "???"
+	}
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+func (bl *WLogger) enqueue(lm *Record) {
+	switch Policy(bl.overflowPolicy.Load()) {
+	case Drop:
+		select {
+		case bl.msgChan <- lm:
+		default:
+			bl.dropped.Add(1)
+			bl.putRecord(lm)
+		}
+	case DropOldest:
+		for {
+			select {
+			case bl.msgChan <- lm:
+				return
+			default:
+			}
+			select {
+			case old := <-bl.msgChan:
+				bl.dropped.Add(1)
+				bl.putRecord(old)
+			default:
+			}
+		}
+	default:
+		bl.msgChan <- lm
+	}
+}
+
+// SetOverflowPolicy sets what happens to messages logged while the async
+// channel is full. The default is Block.
+func (bl *WLogger) SetOverflowPolicy(p Policy) {
+	bl.overflowPolicy.Store(int32(p))
+}
+
+// DroppedCount returns how many messages the overflow policy discarded.
+func (bl *WLogger) DroppedCount() int64 {
+	return bl.dropped.Load()
+}
+
+// Stats holds counters of a WLogger since it was created.
+type Stats struct {
+	Written int64 // messages handed to the outputs
+	Dropped int64 // messages discarded by the overflow policy or a rate limit
+	Errors  int64 // failed adapter writes and flushes
+
+	AfterClose int64 // messages logged after Close, dropped
+}
+
+func (bl *WLogger) Stats() Stats {
+	return Stats{
+		Written: bl.written.Load(),
+		Dropped: bl.dropped.Load(),
+		Errors:  bl.errors.Load(),
+
+		AfterClose: bl.afterClose.Load(),
+	}
+}
+
+// SetBatch makes the async goroutine write up to size queued messages at
+// once, waiting at most maxLatency for a batch to fill. Adapters that support
+// it, like file, then issue a single write per batch. It must be called
+// before Async.
+func (bl *WLogger) SetBatch(size int, maxLatency time.Duration) {
+	bl.batch.Store(&batchConfig{size: size, latency: maxLatency})
+}
+
+// batchConfig holds the settings of SetBatch.
+type batchConfig struct {
+	size    int
+	latency time.Duration
 }
 
 func (bl *WLogger) startLogger() {
-	gameOver := false
+	var batch []*Record
 	for {
 		select {
 		case bm := <-bl.msgChan:
-			bl.writeToLoggers(bm.when, bm.msg, bm.level)
-			logMsgPool.Put(bm)
+			cfg := bl.batch.Load()
+			if cfg == nil || cfg.size <= 1 {
+				bl.writeToLoggers(bm)
+				bl.putRecord(bm)
+				break
+			}
+			batch = bl.collectBatch(append(batch[:0], bm), cfg)
+			bl.writeBatchToLoggers(batch)
+			for _, m := range batch {
+				bl.putRecord(m)
+			}
 		case sg := <-bl.signalChan:
-			bl.flush()
-			if sg == "close" {
-				bl.outputs.Destroy()
-				bl.outputs = nil
-				gameOver = true
+			bl.drain()
+			sg.drained.Done()
+			<-sg.done
+			if sg.op == "close" {
+				return
 			}
-			bl.wg.Done()
 		}
-		if gameOver {
-			break
+	}
+}
+
+func (bl *WLogger) collectBatch(batch []*Record, cfg *batchConfig) []*Record {
+	timer := time.NewTimer(cfg.latency)
+	defer timer.Stop()
+	for len(batch) < cfg.size {
+		select {
+		case bm := <-bl.msgChan:
+			batch = append(batch, bm)
+		case <-timer.C:
+			return batch
 		}
 	}
+	return batch
 }
 
 func (bl *WLogger) Emergency(format string, v ...interface{}) {
-	if LevelEmergency > bl.level {
+	if LevelEmergency > int(bl.level.Load()) {
 		return
 	}
-	bl.WriteMsg(LevelEmergency, format, v...)
+	bl.writeMsg(0, LevelEmergency, sprintf(format, v...), nil)
 }
 
 func (bl *WLogger) Alert(format string, v ...interface{}) {
-	if LevelAlert > bl.level {
+	if LevelAlert > int(bl.level.Load()) {
 		return
 	}
-	bl.WriteMsg(LevelAlert, format, v...)
+	bl.writeMsg(0, LevelAlert, sprintf(format, v...), nil)
 }
 
 func (bl *WLogger) Critical(format string, v ...interface{}) {
-	if LevelCritical > bl.level {
+	if LevelCritical > int(bl.level.Load()) {
 		return
 	}
-	bl.WriteMsg(LevelCritical, format, v...)
+	bl.writeMsg(0, LevelCritical, sprintf(format, v...), nil)
 }
 
 func (bl *WLogger) Error(format string, v ...interface{}) {
-	if LevelError > bl.level {
+	if LevelError > int(bl.level.Load()) {
 		return
 	}
-	bl.WriteMsg(LevelError, format, v...)
+	bl.writeMsg(0, LevelError, sprintf(format, v...), nil)
 }
 
 func (bl *WLogger) Warning(format string, v ...interface{}) {
-	if LevelWarning > bl.level {
+	if LevelWarning > int(bl.level.Load()) {
 		return
 	}
-	bl.WriteMsg(LevelWarning, format, v...)
+	bl.writeMsg(0, LevelWarning, sprintf(format, v...), nil)
 }
 
 func (bl *WLogger) Notice(format string, v ...interface{}) {
-	if LevelNotice > bl.level {
+	if LevelNotice > int(bl.level.Load()) {
 		return
 	}
-	bl.WriteMsg(LevelNotice, format, v...)
+	bl.writeMsg(0, LevelNotice, sprintf(format, v...), nil)
 }
 
 func (bl *WLogger) Informational(format string, v ...interface{}) {
-	if LevelInformational > bl.level {
+	if LevelInformational > int(bl.level.Load()) {
 		return
 	}
-	bl.WriteMsg(LevelInformational, format, v...)
+	bl.writeMsg(0, LevelInformational, sprintf(format, v...), nil)
 }
 
 func (bl *WLogger) Debug(format string, v ...interface{}) {
-	if LevelDebug > bl.level {
+	if LevelDebug > int(bl.level.Load()) {
 		return
 	}
-	bl.WriteMsg(LevelDebug, format, v...)
+	bl.writeMsg(0, LevelDebug, sprintf(format, v...), nil)
 }
 
 func (bl *WLogger) Warn(format string, v ...interface{}) {
-	if LevelWarning > bl.level {
+	if LevelWarning > int(bl.level.Load()) {
 		return
 	}
-	bl.WriteMsg(LevelWarn, format, v...)
+	bl.writeMsg(0, LevelWarn, sprintf(format, v...), nil)
 }
 
 func (bl *WLogger) Info(format string, v ...interface{}) {
-	if LevelInformational > bl.level {
+	if LevelInformational > int(bl.level.Load()) {
 		return
 	}
-	bl.WriteMsg(LevelInformational, format, v...)
+	bl.writeMsg(0, LevelInformational, sprintf(format, v...), nil)
 }
 
 func (bl *WLogger) Trace(format string, v ...interface{}) {
-	if LevelDebug > bl.level {
+	if LevelDebug > int(bl.level.Load()) {
+		return
+	}
+	bl.writeMsg(0, LevelTrace, sprintf(format, v...), nil)
+}
+
+// DebugFunc logs the message returned by f, calling f only if the message
+// would be written.
+func (bl *WLogger) DebugFunc(f func() string) {
+	if !bl.Enabled(LevelDebug) {
+		return
+	}
+	bl.writeMsg(0, LevelDebug, f(), nil)
+}
+
+// TraceFunc is like DebugFunc at LevelTrace.
+func (bl *WLogger) TraceFunc(f func() string) {
+	if !bl.Enabled(LevelTrace) {
 		return
 	}
-	bl.WriteMsg(LevelTrace, format, v...)
+	bl.writeMsg(0, LevelTrace, f(), nil)
+}
+
+// Fatal writes the message at LevelEmergency, flushes and calls os.Exit(1).
+func (bl *WLogger) Fatal(format string, v ...interface{}) {
+	bl.writeMsg(0, LevelEmergency, sprintf(format, v...), nil)
+	bl.Flush()
+	os.Exit(1)
+}
+
+// Panic writes the message at LevelEmergency, flushes and panics with it.
+func (bl *WLogger) Panic(format string, v ...interface{}) {
+	msg := sprintf(format, v...)
+	bl.writeMsg(0, LevelEmergency, msg, nil)
+	bl.Flush()
+	panic(msg)
+}
+
+// signal has every worker drain the channel and park, then flushes, or
+// for "close" destroys, the outputs before releasing them.
+func (bl *WLogger) signal(op string) {
+	bl.signalLock.Lock()
+	defer bl.signalLock.Unlock()
+
+	var drained sync.WaitGroup
+	done := make(chan struct{})
+	drained.Add(bl.workers)
+	for i := 0; i < bl.workers; i++ {
+		bl.signalChan <- logSignal{op: op, drained: &drained, done: done}
+	}
+	drained.Wait()
+
+	bl.flush()
+	if op == "close" {
+		for _, l := range bl.loadOutputs() {
+			l.Destroy()
+		}
+		bl.storeOutputs(nil)
+	}
+	close(done)
 }
 
 func (bl *WLogger) Flush() {
+	bl.closeLock.RLock()
+	defer bl.closeLock.RUnlock()
+	if bl.closed {
+		return
+	}
+
 	if bl.asynchronous {
-		bl.signalChan <- "flush"
-		bl.wg.Wait()
-		bl.wg.Add(1)
+		bl.signal("flush")
 		return
 	}
 	bl.flush()
 }
 
+// errFlusher is implemented by outputs whose flush can fail. flushErr
+// flushes as Flush does and returns the error instead of reporting it.
+type errFlusher interface {
+	flushErr() error
+}
+
+// reopener is implemented by outputs writing to a file that can be
+// reopened under the same name.
+type reopener interface {
+	Reopen() error
+}
+
+// Reopen reopens the files of all outputs that write to one, typically from
+// a SIGHUP handler once logrotate has moved them away. It returns the first
+// error.
+func (bl *WLogger) Reopen() error {
+	bl.closeLock.RLock()
+	defer bl.closeLock.RUnlock()
+	if bl.closed {
+		return nil
+	}
+
+	var firstErr error
+	for _, l := range bl.loadOutputs() {
+		if r, ok := l.Logger.(reopener); ok {
+			if err := r.Reopen(); err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("adapter %s: %w", l.name, err)
+			}
+		}
+	}
+	return firstErr
+}
+
+// rotator is implemented by outputs that can roll their file over on
+// demand.
+type rotator interface {
+	rotate() error
+}
+
+// Rotate makes the file outputs rename their file to the next rotated name
+// and start a new one now, whatever their size and date.
+func (bl *WLogger) Rotate() error {
+	bl.closeLock.RLock()
+	defer bl.closeLock.RUnlock()
+	if bl.closed {
+		return nil
+	}
+
+	var firstErr error
+	for _, l := range bl.loadOutputs() {
+		if r, ok := l.Logger.(rotator); ok {
+			if err := r.rotate(); err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("adapter %s: %w", l.name, err)
+			}
+		}
+	}
+	return firstErr
+}
+
+// Check runs HealthCheck on the outputs implementing HealthChecker, such as
+// file outputs, which make sure their file is open and writable, and
+// network outputs, which make sure their peer can be reached. It returns
+// the first error, naming the adapter, and is meant for startup and
+// readiness checks.
+func (bl *WLogger) Check() error {
+	if err := bl.lazyInit(); err != nil {
+		return err
+	}
+	bl.closeLock.RLock()
+	defer bl.closeLock.RUnlock()
+	if bl.closed {
+		return errors.New("logger is closed")
+	}
+
+	var firstErr error
+	for _, l := range bl.loadOutputs() {
+		if c, ok := l.Logger.(HealthChecker); ok {
+			if err := c.HealthCheck(); err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("adapter %s: %w", l.name, err)
+			}
+		}
+	}
+	return firstErr
+}
+
+// FlushTimeout is Flush giving up after d, for callers that cannot wait on
+// a stuck output. The flush carries on in the background after a timeout.
+func (bl *WLogger) FlushTimeout(d time.Duration) error {
+	done := make(chan struct{})
+	go func() {
+		bl.Flush()
+		close(done)
+	}()
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("flush timed out after %s", d)
+	}
+}
+
+// Close waits for writes in flight, drains the async channel and destroys
+// all outputs. Calling it again does nothing. Messages logged after it are
+// dropped and counted in Stats.AfterClose.
 func (bl *WLogger) Close() {
+	bl.closeLock.Lock()
+	if bl.closed {
+		bl.closeLock.Unlock()
+		return
+	}
+	bl.closed = true
+	bl.closeLock.Unlock()
+
+	if d := bl.dedup.Load(); d != nil {
+		if repeats, level := d.pending(); repeats > 0 {
+			bl.repeatNotice(repeats, level, bl.clock.now().Local())
+		}
+	}
+	for level := range bl.limiters {
+		if r := bl.limiters[level].Load(); r != nil {
+			if n := r.pending(); n > 0 {
+				bl.suppressedNotice(n, level, bl.clock.now().Local())
+			}
+		}
+	}
 	if bl.asynchronous {
-		bl.signalChan <- "close"
-		bl.wg.Wait()
-		close(bl.msgChan)
-	} else {
-		bl.flush()
-		bl.outputs.Destroy()
-		bl.outputs = nil
+		bl.signal("close")
+		return
+	}
+	bl.flush()
+	for _, l := range bl.loadOutputs() {
+		l.Destroy()
 	}
-	close(bl.signalChan)
+	bl.storeOutputs(nil)
 }
 
+// Closed reports whether Close has been called.
+func (bl *WLogger) Closed() bool {
+	bl.closeLock.RLock()
+	defer bl.closeLock.RUnlock()
+	return bl.closed
+}
+
+// WarnAfterClose makes the first message logged after Close report that
+// messages are being dropped, on the error output. Off by default, so
+// goroutines still logging during shutdown go unnoticed.
+func (bl *WLogger) WarnAfterClose(b bool) {
+	bl.warnAfterClose.Store(b)
+}
+
+func (bl *WLogger) droppedAfterClose() {
+	bl.afterClose.Add(1)
+	if bl.warnAfterClose.Load() && bl.closeWarned.CompareAndSwap(false, true) {
+		bl.errOut.printf("wlog: message logged after Close, dropping it and any later ones\n")
+	}
+}
+
+// Reset flushes and destroys the outputs, then sets each one up again from
+// the config it was added with, reopening files and connections. Outputs
+// not added by SetLogger, like those of AddWriter, are kept as they are.
 func (bl *WLogger) Reset() {
 	bl.Flush()
-	bl.outputs.Destroy()
-	bl.outputs = nil
+	bl.lock.Lock()
+	defer bl.lock.Unlock()
+	outputs := bl.loadOutputs()
+	bl.storeOutputs(nil)
+	for _, l := range outputs {
+		if !l.configured {
+			bl.addOutput(l)
+			continue
+		}
+		l.Destroy()
+		// setLogger reports its errors on stderr.
+		bl.setLogger(l.name, l.adapter, l.config)
+	}
+}
+
+// sprintf is fmt.Sprintf, skipping the work for a format without verbs.
+func sprintf(format string, v ...interface{}) string {
+	if len(v) == 0 && strings.IndexByte(format, '%') < 0 {
+		return format
+	}
+	return fmt.Sprintf(format, v...)
+}
+
+// sprintln is fmt.Sprintln without the newline.
+func sprintln(v ...interface{}) string {
+	s := fmt.Sprintln(v...)
+	return s[:len(s)-1]
 }
 
 func (bl *WLogger) flush() {
 	if bl.asynchronous {
-		for {
-			if len(bl.msgChan) > 0 {
-				bm := <-bl.msgChan
-				bl.writeToLoggers(bm.when, bm.msg, bm.level)
-				logMsgPool.Put(bm)
-				continue
+		bl.drain()
+	}
+	for _, l := range bl.loadOutputs() {
+		if f, ok := l.Logger.(errFlusher); ok {
+			if err := f.flushErr(); err != nil {
+				bl.adapterError(l, err)
 			}
-			break
+			continue
+		}
+		l.Flush()
+	}
+}
+
+// drain writes the messages queued at the time of the call.
+func (bl *WLogger) drain() {
+	for {
+		select {
+		case bm := <-bl.msgChan:
+			bl.writeToLoggers(bm)
+			bl.putRecord(bm)
+		default:
+			return
 		}
 	}
-	bl.outputs.Flush()
 }
@@ -0,0 +1,1169 @@
+package wlog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer safe for the concurrent writes of outputs.
+type syncBuffer struct {
+	sync.Mutex
+	b bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	return b.b.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.Lock()
+	defer b.Unlock()
+	return b.b.String()
+}
+
+// output returns the adapter added under name.
+func output(t *testing.T, bl *WLogger, name string) Logger {
+	t.Helper()
+	for _, l := range bl.loadOutputs() {
+		if l.name == name {
+			return l.Logger
+		}
+	}
+	t.Fatalf("no output %q", name)
+	return nil
+}
+
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestPerOutputLevel(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	bl := NewLogger()
+	if err := bl.SetLogger(AdapterConsole, fmt.Sprintf(`{"level":%d}`, LevelInfo)); err != nil {
+		t.Fatal(err)
+	}
+	var console syncBuffer
+	output(t, bl, AdapterConsole).(*consoleLogWriter).stdout = newLogWriter(&console)
+	if err := bl.SetLogger(AdapterFile, fmt.Sprintf(`{"filename":%q,"level":%d}`, name, LevelDebug)); err != nil {
+		t.Fatal(err)
+	}
+
+	bl.Debug("debug line")
+	bl.Info("info line")
+	bl.Close()
+
+	tests := []struct {
+		output, text string
+		want         bool
+	}{
+		{"console", "debug line", false},
+		{"console", "info line", true},
+		{"file", "debug line", true},
+		{"file", "info line", true},
+	}
+	got := map[string]string{"console": console.String(), "file": readFile(t, name)}
+	for _, tt := range tests {
+		if strings.Contains(got[tt.output], tt.text) != tt.want {
+			t.Errorf("%s contains %q = %v, want %v:\n%s", tt.output, tt.text, !tt.want, tt.want, got[tt.output])
+		}
+	}
+}
+
+func TestDelLogger(t *testing.T) {
+	bl := NewLogger()
+	var a, b syncBuffer
+	bl.AddWriter(&a, LevelDebug)
+	if err := bl.SetNamedLogger("mem", AdapterMemory); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bl.DelNamedLogger("nope"); err == nil {
+		t.Error("DelNamedLogger of an unknown name succeeded")
+	}
+	if err := bl.DelNamedLogger("mem"); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(bl.loadOutputs()); n != 1 {
+		t.Fatalf("%d outputs after DelNamedLogger, want 1", n)
+	}
+	if err := bl.DelLogger(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(bl.loadOutputs()); n != 0 {
+		t.Fatalf("%d outputs after DelLogger, want 0", n)
+	}
+	bl.AddWriter(&b, LevelDebug)
+	bl.Info("after")
+	if strings.Contains(a.String(), "after") || !strings.Contains(b.String(), "after") {
+		t.Errorf("removed output written to: %q, %q", a.String(), b.String())
+	}
+}
+
+// TestOutputsRace adds and removes outputs while other goroutines log; run
+// it with -race. Files of removed outputs must not be opened again.
+func TestOutputsRace(t *testing.T) {
+	dir := t.TempDir()
+	bl := NewLogger()
+	bl.SetErrorOutput(&syncBuffer{})
+	if err := bl.SetLogger(AdapterMemory); err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					bl.Info("hello")
+				}
+			}
+		}()
+	}
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("f%d", i)
+		if err := bl.SetNamedLogger(name, AdapterFile, fmt.Sprintf(`{"filename":%q}`, filepath.Join(dir, name+".log"))); err != nil {
+			t.Fatal(err)
+		}
+		bl.Enabled(LevelInfo)
+		if err := bl.DelNamedLogger(name); err != nil {
+			t.Fatal(err)
+		}
+		os.Remove(filepath.Join(dir, name+".log"))
+	}
+	close(stop)
+	wg.Wait()
+
+	bl.Info("done")
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		t.Errorf("%s reopened after its output was removed", e.Name())
+	}
+}
+
+func TestFileWriteAfterDestroy(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	w := newFileWriter().(*fileLogWriter)
+	if err := w.Init(fmt.Sprintf(`{"filename":%q}`, name)); err != nil {
+		t.Fatal(err)
+	}
+	w.Destroy()
+	os.Remove(name)
+	if err := w.WriteMsg(w.now(), "late", LevelInfo); err != os.ErrClosed {
+		t.Errorf("WriteMsg after Destroy = %v, want os.ErrClosed", err)
+	}
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("file opened again after Destroy: %v", err)
+	}
+}
+
+func TestLazyInitError(t *testing.T) {
+	// A regular file in place of the directory makes the file output fail.
+	notDir := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(notDir, nil, 0666); err != nil {
+		t.Fatal(err)
+	}
+	bl := NewLogger()
+	bl.SetErrorOutput(&syncBuffer{})
+	bl.SetDefaultLogger(AdapterFile, fmt.Sprintf(`{"filename":%q}`, filepath.Join(notDir, "app.log")))
+
+	for i := 0; i < 3; i++ {
+		if err := bl.WriteMsg(LevelInfo, "lost %d", i); err == nil {
+			t.Errorf("write %d returned no error", i)
+		}
+	}
+	if err := bl.Init(); err == nil {
+		t.Error("Init returned no error")
+	}
+	if s := bl.Stats(); s.Written != 0 {
+		t.Errorf("Written = %d, want 0", s.Written)
+	}
+
+	// A working output clears the error.
+	if err := bl.SetLogger(AdapterMemory); err != nil {
+		t.Fatal(err)
+	}
+	if err := bl.WriteMsg(LevelInfo, "kept"); err != nil {
+		t.Errorf("write after SetLogger: %v", err)
+	}
+	if s := bl.Stats(); s.Written != 1 {
+		t.Errorf("Written = %d, want 1", s.Written)
+	}
+}
+
+// redirectStd points os.Stdout and os.Stderr at files in a temporary
+// directory for the rest of the test and returns their paths.
+func redirectStd(t *testing.T) (stdout, stderr string) {
+	dir := t.TempDir()
+	stdout, stderr = filepath.Join(dir, "stdout"), filepath.Join(dir, "stderr")
+	outFile, err := os.Create(stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errFile, err := os.Create(stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldOut, oldErr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = outFile, errFile
+	t.Cleanup(func() {
+		os.Stdout, os.Stderr = oldOut, oldErr
+		outFile.Close()
+		errFile.Close()
+	})
+	return stdout, stderr
+}
+
+// TestDefaultOutput logs without SetLogger: the console output is set up,
+// not a file output without a filename.
+func TestDefaultOutput(t *testing.T) {
+	stdout, stderr := redirectStd(t)
+	bl := NewLogger()
+	bl.Info("to stdout")
+	bl.Error("to stderr")
+	bl.Close()
+
+	if s := readFile(t, stdout); !strings.Contains(s, "[I] to stdout") || strings.Contains(s, "to stderr") {
+		t.Errorf("stdout = %q", s)
+	}
+	if s := readFile(t, stderr); !strings.Contains(s, "[E] to stderr") || strings.Contains(s, "to stdout") {
+		t.Errorf("stderr = %q", s)
+	}
+
+	// Without a default adapter, writes fail until SetLogger.
+	bl = NewLogger()
+	bl.SetErrorOutput(&syncBuffer{})
+	bl.SetDefaultLogger("")
+	if err := bl.WriteMsg(LevelInfo, "lost"); err == nil {
+		t.Error("write without an output returned no error")
+	}
+	if err := bl.Init(); err == nil {
+		t.Error("Init without an output returned no error")
+	}
+}
+
+// TestLazyInitRace writes from many goroutines to a logger without
+// SetLogger: the default output is set up once and gets every message.
+func TestLazyInitRace(t *testing.T) {
+	const goroutines, msgs = 16, 50
+	bl := NewLogger()
+	bl.SetDefaultLogger(AdapterMemory)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			<-start
+			for i := 0; i < msgs; i++ {
+				if err := bl.WriteMsg(LevelInfo, "g%d %d", g, i); err != nil {
+					t.Errorf("WriteMsg: %v", err)
+					return
+				}
+			}
+		}(g)
+	}
+	close(start)
+	wg.Wait()
+
+	if n := len(bl.loadOutputs()); n != 1 {
+		t.Fatalf("%d outputs, want the default one", n)
+	}
+	m := output(t, bl, AdapterMemory).(*MemoryWriter)
+	if n := len(m.Messages()); n != goroutines*msgs {
+		t.Errorf("%d messages, want %d", n, goroutines*msgs)
+	}
+}
+
+// TestReset checks that Reset sets the outputs up again with their config
+// and keeps those added by AddWriter.
+func TestReset(t *testing.T) {
+	stdout, stderr := redirectStd(t)
+	bl := NewLogger()
+	if err := bl.SetLogger(AdapterConsole, `{"output":"stdout"}`); err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(t.TempDir(), "warn.log")
+	if err := bl.SetNamedLogger("warnings", AdapterFile, fmt.Sprintf(`{"filename":%q,"level":%d}`, name, LevelWarning)); err != nil {
+		t.Fatal(err)
+	}
+	var out syncBuffer
+	bl.AddWriter(&out, LevelDebug)
+	before := output(t, bl, AdapterConsole)
+
+	bl.Reset()
+	if output(t, bl, AdapterConsole) == before {
+		t.Error("Reset kept the console output instead of setting it up again")
+	}
+	bl.Info("after reset")
+	bl.Error("failed after reset")
+	bl.Close()
+
+	if s := readFile(t, stdout); !strings.Contains(s, "[I] after reset") || !strings.Contains(s, "[E] failed after reset") {
+		t.Errorf("stdout = %q, want both lines", s)
+	}
+	if s := readFile(t, stderr); s != "" {
+		t.Errorf("stderr = %q, want nothing", s)
+	}
+	if s := readFile(t, name); strings.Contains(s, "[I]") || !strings.Contains(s, "failed after reset") {
+		t.Errorf("warn.log = %q, want the error alone", s)
+	}
+	if s := out.String(); !strings.Contains(s, "after reset") {
+		t.Errorf("writer output = %q, want the lines after Reset", s)
+	}
+}
+
+// TestSettersRace flips the level and caller settings while other
+// goroutines log, for the race detector.
+func TestSettersRace(t *testing.T) {
+	bl := NewLogger()
+	var out syncBuffer
+	bl.AddWriter(&out, LevelDebug)
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					bl.Debug("debug")
+					bl.Info("info")
+					bl.Errorln("error")
+					bl.Enabled(LevelInfo)
+					bl.GetLevel()
+				}
+			}
+		}()
+	}
+	for i := 0; i < 200; i++ {
+		bl.SetLevel(i % (LevelDebug + 1))
+		bl.EnableFuncCallDepth(i%2 == 0)
+		bl.EnableFuncName(i%3 == 0)
+		bl.SetLogFuncCallDepth(2 + i%2)
+		bl.SetCallerMinLevel(i % (LevelDebug + 1))
+		bl.SetPrefix(fmt.Sprint("p", i%4))
+	}
+	close(stop)
+	wg.Wait()
+
+	bl.SetLevel(LevelError)
+	bl.Info("filtered")
+	if strings.HasSuffix(out.String(), "filtered\n") {
+		t.Error("Info logged at level error")
+	}
+}
+
+// failWriter fails every write with err.
+type failWriter struct{ err error }
+
+func (w failWriter) Write(p []byte) (int, error) { return 0, w.err }
+
+func TestWriteErrors(t *testing.T) {
+	errBroken := errors.New("broken pipe")
+	tests := []struct {
+		name  string
+		setup func(bl *WLogger)
+	}{
+		{"writer", func(bl *WLogger) { bl.AddWriter(failWriter{errBroken}, LevelDebug) }},
+		{"console", func(bl *WLogger) {
+			bl.SetLogger(AdapterConsole)
+			output(t, bl, AdapterConsole).(*consoleLogWriter).stdout = newLogWriter(failWriter{errBroken})
+		}},
+	}
+	for _, tt := range tests {
+		for _, async := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/async=%v", tt.name, async), func(t *testing.T) {
+				bl := NewLogger()
+				tt.setup(bl)
+				var mu sync.Mutex
+				var got []error
+				bl.OnError(func(err error) {
+					mu.Lock()
+					got = append(got, err)
+					mu.Unlock()
+				})
+				if async {
+					bl.SetBatch(4, time.Millisecond)
+					bl.SetOverflowPolicy(Drop)
+					bl.Async()
+				}
+
+				err := bl.WriteMsg(LevelInfo, "lost")
+				if !async && !errors.Is(err, errBroken) {
+					t.Errorf("WriteMsg = %v, want %v", err, errBroken)
+				}
+				bl.Close()
+
+				mu.Lock()
+				defer mu.Unlock()
+				if len(got) != 1 || !errors.Is(got[0], errBroken) {
+					t.Errorf("OnError got %v, want one %v", got, errBroken)
+				}
+				if n := bl.Stats().Errors; n != 1 {
+					t.Errorf("Stats.Errors = %d, want 1", n)
+				}
+			})
+		}
+	}
+}
+
+func TestChanLen(t *testing.T) {
+	tests := []struct {
+		lens   []int64
+		want   int64
+		capped bool
+	}{
+		{nil, 1000, false},
+		{[]int64{0}, 1000, false},
+		{[]int64{-5}, 1000, false},
+		{[]int64{10}, 10, false},
+		{[]int64{maxAsyncMsgLen}, maxAsyncMsgLen, false},
+		{[]int64{maxAsyncMsgLen + 1}, maxAsyncMsgLen, true},
+	}
+	for _, tt := range tests {
+		bl := NewLogger()
+		var errOut syncBuffer
+		bl.SetErrorOutput(&errOut)
+		if got := bl.chanLen(tt.lens, 1000); got != tt.want {
+			t.Errorf("chanLen(%v) = %d, want %d", tt.lens, got, tt.want)
+		}
+		if capped := strings.Contains(errOut.String(), "capped"); capped != tt.capped {
+			t.Errorf("chanLen(%v) reported capping %v, want %v", tt.lens, capped, tt.capped)
+		}
+	}
+}
+
+func TestOddFields(t *testing.T) {
+	tests := []struct {
+		name string
+		log  func(bl *WLogger)
+	}{
+		{"With", func(bl *WLogger) { bl.With("a", 1, "dangling").Info("msg") }},
+		{"Entry.With", func(bl *WLogger) { bl.With("a", 1).With("dangling").Info("msg") }},
+		{"Infow", func(bl *WLogger) { bl.Infow("msg", "a", 1, "dangling") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bl := NewLogger()
+			var out, errOut syncBuffer
+			bl.SetErrorOutput(&errOut)
+			bl.AddWriter(&out, LevelDebug)
+			tt.log(bl)
+			if !strings.Contains(errOut.String(), "dropping key dangling") {
+				t.Errorf("error output %q lacks the dropped key", errOut.String())
+			}
+			if s := out.String(); !strings.Contains(s, "a=1") || strings.Contains(s, "dangling") {
+				t.Errorf("output %q, want a=1 without dangling", s)
+			}
+		})
+	}
+}
+
+func TestSprintf(t *testing.T) {
+	tests := []struct {
+		format string
+		v      []interface{}
+		want   string
+	}{
+		{"plain message", nil, "plain message"},
+		{"n=%d", []interface{}{3}, "n=3"},
+		{"%s and %s", []interface{}{"a", "b"}, "a and b"},
+		// A % without arguments is still a verb, as documented.
+		{"100%", nil, "100%!(NOVERB)"},
+		{"100%%", nil, "100%"},
+	}
+	for _, tt := range tests {
+		if got := sprintf(tt.format, tt.v...); got != tt.want {
+			t.Errorf("sprintf(%q, %v) = %q, want %q", tt.format, tt.v, got, tt.want)
+		}
+	}
+
+	bl := NewLogger()
+	var out syncBuffer
+	bl.AddWriter(&out, LevelDebug)
+	bl.Info("n=%d", 3)
+	bl.Info("plain message")
+	for _, want := range []string{"n=3\n", "plain message\n"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestPanic(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	bl := NewLogger()
+	if err := bl.SetLogger(AdapterFile, fmt.Sprintf(`{"filename":%q}`, name)); err != nil {
+		t.Fatal(err)
+	}
+	bl.Async()
+	defer bl.Close()
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom 7" {
+				t.Errorf("recovered %v, want boom 7", r)
+			}
+		}()
+		bl.Panic("boom %d", 7)
+	}()
+	// Panic flushed the async channel before unwinding.
+	if s := readFile(t, name); !strings.Contains(s, "boom 7") {
+		t.Errorf("file lacks the message:\n%s", s)
+	}
+}
+
+func TestFatal(t *testing.T) {
+	if name := os.Getenv("WLOG_TEST_FATAL"); name != "" {
+		bl := NewLogger()
+		if err := bl.SetLogger(AdapterFile, fmt.Sprintf(`{"filename":%q}`, name)); err != nil {
+			t.Fatal(err)
+		}
+		bl.Async()
+		bl.Fatal("fatal %d", 1)
+		return
+	}
+
+	name := filepath.Join(t.TempDir(), "app.log")
+	cmd := exec.Command(os.Args[0], "-test.run=^TestFatal$")
+	cmd.Env = append(os.Environ(), "WLOG_TEST_FATAL="+name)
+	err := cmd.Run()
+	var exit *exec.ExitError
+	if !errors.As(err, &exit) || exit.ExitCode() != 1 {
+		t.Fatalf("exit = %v, want status 1", err)
+	}
+	if s := readFile(t, name); !strings.Contains(s, "fatal 1") {
+		t.Errorf("file lacks the message:\n%s", s)
+	}
+}
+
+// TestFlushStress logs from many goroutines while others flush, then
+// closes; run it with -race.
+func TestFlushStress(t *testing.T) {
+	for _, workers := range []int64{1, 4} {
+		t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+			bl := NewLogger()
+			var out syncBuffer
+			bl.AddWriter(&out, LevelDebug)
+			bl.Async(64, workers)
+
+			const loggers, msgs = 8, 200
+			var logging, flushing sync.WaitGroup
+			stop := make(chan struct{})
+			for i := 0; i < 3; i++ {
+				flushing.Add(1)
+				go func() {
+					defer flushing.Done()
+					for {
+						select {
+						case <-stop:
+							return
+						default:
+							bl.Flush()
+						}
+					}
+				}()
+			}
+			for i := 0; i < loggers; i++ {
+				logging.Add(1)
+				go func(i int) {
+					defer logging.Done()
+					for j := 0; j < msgs; j++ {
+						bl.Info("g%d m%d", i, j)
+					}
+				}(i)
+			}
+			logging.Wait()
+			close(stop)
+			flushing.Wait()
+			bl.Close()
+			bl.Flush()
+			bl.Close()
+
+			if n := strings.Count(out.String(), "\n"); n != loggers*msgs {
+				t.Errorf("%d lines written, want %d", n, loggers*msgs)
+			}
+		})
+	}
+}
+
+// TestAsyncLoggersIsolated runs two async loggers side by side; each must
+// write exactly its own messages.
+func TestAsyncLoggersIsolated(t *testing.T) {
+	const msgs = 500
+	names := []string{"A", "B"}
+	outs := make([]syncBuffer, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(name string, out *syncBuffer) {
+			defer wg.Done()
+			bl := NewLogger()
+			bl.AddWriter(out, LevelDebug)
+			bl.Async(16)
+			for j := 0; j < msgs; j++ {
+				bl.Info("%s%d", name, j)
+			}
+			bl.Close()
+		}(name, &outs[i])
+	}
+	wg.Wait()
+
+	for i, name := range names {
+		lines := strings.Split(strings.TrimSuffix(outs[i].String(), "\n"), "\n")
+		if len(lines) != msgs {
+			t.Errorf("logger %s wrote %d lines, want %d", name, len(lines), msgs)
+		}
+		for j, line := range lines {
+			if !strings.HasSuffix(line, fmt.Sprintf(" %s%d", name, j)) {
+				t.Errorf("logger %s line %d = %q", name, j, line)
+				break
+			}
+		}
+	}
+}
+
+// blockWriter holds its first write until release is closed.
+type blockWriter struct {
+	syncBuffer
+	once    sync.Once
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlockWriter() *blockWriter {
+	return &blockWriter{started: make(chan struct{}), release: make(chan struct{})}
+}
+
+func (w *blockWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() {
+		close(w.started)
+		<-w.release
+	})
+	return w.syncBuffer.Write(p)
+}
+
+func TestOverflowPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		written []int
+		dropped int64
+	}{
+		{"block", Block, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, 0},
+		{"drop", Drop, []int{0, 1, 2, 3, 4}, 6},
+		{"dropoldest", DropOldest, []int{0, 7, 8, 9, 10}, 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bl := NewLogger()
+			w := newBlockWriter()
+			bl.AddWriter(w, LevelDebug)
+			bl.SetOverflowPolicy(tt.policy)
+			bl.Async(4)
+
+			// The worker takes message 0 and blocks on it, the channel
+			// holds four of the next ten.
+			bl.Info("msg 0")
+			<-w.started
+			logged := make(chan struct{})
+			go func() {
+				for i := 1; i <= 10; i++ {
+					bl.Info("msg %d", i)
+				}
+				close(logged)
+			}()
+			select {
+			case <-logged:
+				if tt.policy == Block {
+					t.Error("logging did not block on a full channel")
+				}
+			case <-time.After(50 * time.Millisecond):
+				if tt.policy != Block {
+					t.Fatal("logging blocked")
+				}
+			}
+			close(w.release)
+			<-logged
+			bl.Close()
+
+			var got []int
+			for _, line := range strings.Split(strings.TrimSuffix(w.String(), "\n"), "\n") {
+				var n int
+				fmt.Sscanf(line[strings.LastIndex(line, "msg "):], "msg %d", &n)
+				got = append(got, n)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.written) {
+				t.Errorf("written %v, want %v", got, tt.written)
+			}
+			if n := bl.DroppedCount(); n != tt.dropped {
+				t.Errorf("DroppedCount = %d, want %d", n, tt.dropped)
+			}
+		})
+	}
+}
+
+func TestStats(t *testing.T) {
+	for _, async := range []bool{false, true} {
+		t.Run(fmt.Sprintf("async=%v", async), func(t *testing.T) {
+			bl := NewLogger()
+			bl.OnError(func(error) {})
+			var out syncBuffer
+			bl.AddWriter(&out, LevelDebug)
+			bl.AddWriter(failWriter{errors.New("disk full")}, LevelError)
+			bl.SetLevel(LevelInfo)
+			if async {
+				bl.Async()
+			}
+
+			bl.Info("one")
+			bl.Info("two")
+			bl.Debug("filtered")
+			bl.Error("three")
+			bl.Error("four")
+			bl.Close()
+			bl.Info("late")
+
+			want := Stats{Written: 4, Errors: 2, AfterClose: 1}
+			if got := bl.Stats(); got != want {
+				t.Errorf("Stats = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+// thisLine returns the line it is called from.
+func thisLine() int {
+	_, _, line, _ := runtime.Caller(1)
+	return line
+}
+
+// TestCallerDepth checks that every entry point reports the line of its
+// own call.
+func TestCallerDepth(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		log  func(bl *WLogger) int
+	}{
+		{"Info", func(bl *WLogger) int { bl.Info("x"); return thisLine() }},
+		{"WriteMsg", func(bl *WLogger) int { bl.WriteMsg(LevelInfo, "x"); return thisLine() }},
+		{"Infoln", func(bl *WLogger) int { bl.Infoln("x"); return thisLine() }},
+		{"Infow", func(bl *WLogger) int { bl.Infow("x", "k", 1); return thisLine() }},
+		{"Entry", func(bl *WLogger) int { bl.With("k", 1).Info("x"); return thisLine() }},
+		{"InfoContext", func(bl *WLogger) int { bl.InfoContext(ctx, "x"); return thisLine() }},
+		{"DebugFunc", func(bl *WLogger) int { bl.DebugFunc(func() string { return "x" }); return thisLine() }},
+		{"package", func(bl *WLogger) int { Info("x"); return thisLine() }},
+		{"StdLogger", func(bl *WLogger) int { bl.StdLogger(LevelInfo).Print("x"); return thisLine() }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bl := NewLogger()
+			var out syncBuffer
+			bl.AddWriter(&out, LevelDebug)
+			bl.EnableFuncCallDepth(true)
+			old := Default()
+			SetDefault(bl)
+			defer SetDefault(old)
+
+			line := tt.log(bl)
+			if want := fmt.Sprintf("[log_test.go:%d]", line); !strings.Contains(out.String(), want) {
+				t.Errorf("got %q, want caller %s", out.String(), want)
+			}
+		})
+	}
+}
+
+func TestCallerFuncName(t *testing.T) {
+	bl := NewLogger()
+	var out syncBuffer
+	bl.AddWriter(&out, LevelDebug)
+	bl.EnableFuncCallDepth(true)
+	logLine := func(msg string) int { bl.Info("%s", msg); return thisLine() }
+
+	off := logLine("off")
+	bl.EnableFuncName(true)
+	on := logLine("on")
+	for _, want := range []string{
+		fmt.Sprintf("[log_test.go:%d]off", off),
+		fmt.Sprintf("[log_test.go:%d wlog.TestCallerFuncName.func1]on", on),
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("got %q, want %s", out.String(), want)
+		}
+	}
+}
+
+func TestCallerMinLevel(t *testing.T) {
+	bl := NewLogger()
+	var out syncBuffer
+	bl.AddWriter(&out, LevelDebug)
+	bl.EnableFuncCallDepth(true)
+	bl.SetCallerMinLevel(LevelWarning)
+
+	bl.Debug("debug")
+	bl.Warn("warned")
+	warned := thisLine() - 1
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %q, want 2 lines", out.String())
+	}
+	if strings.Contains(lines[0], "log_test.go") {
+		t.Errorf("debug line %q has caller info", lines[0])
+	}
+	if want := fmt.Sprintf("[log_test.go:%d]", warned); !strings.Contains(lines[1], want) {
+		t.Errorf("warning line %q lacks caller %s", lines[1], want)
+	}
+}
+
+func TestCloseTwice(t *testing.T) {
+	for _, async := range []bool{false, true} {
+		for _, reset := range []bool{false, true} {
+			t.Run(fmt.Sprintf("async=%v/reset=%v", async, reset), func(t *testing.T) {
+				bl := NewLogger()
+				var w syncBuffer
+				bl.AddWriter(&w, LevelDebug)
+				if async {
+					bl.Async(1000)
+				}
+				for i := 0; i < 100; i++ {
+					bl.Info("msg %d", i)
+				}
+				if reset {
+					bl.Reset()
+				}
+				bl.Close()
+				// Close drained the channel before destroying the outputs.
+				if n := strings.Count(w.String(), "\n"); n != 100 {
+					t.Errorf("%d lines written, want 100", n)
+				}
+
+				bl.Close()
+				if err := bl.WriteMsg(LevelInfo, "late"); err != nil {
+					t.Errorf("WriteMsg after Close = %v", err)
+				}
+				bl.Info("late")
+				bl.Flush()
+				if strings.Contains(w.String(), "late") {
+					t.Error("message after Close written")
+				}
+				if n := bl.Stats().AfterClose; n != 2 {
+					t.Errorf("AfterClose = %d, want 2", n)
+				}
+			})
+		}
+	}
+}
+
+func TestWarnAfterClose(t *testing.T) {
+	for _, async := range []bool{false, true} {
+		for _, warn := range []bool{false, true} {
+			t.Run(fmt.Sprintf("async=%v/warn=%v", async, warn), func(t *testing.T) {
+				bl := NewLogger()
+				var out, errOut syncBuffer
+				bl.AddWriter(&out, LevelDebug)
+				bl.SetErrorOutput(&errOut)
+				bl.WarnAfterClose(warn)
+				if async {
+					bl.Async(10)
+				}
+				if bl.Closed() {
+					t.Error("Closed() before Close")
+				}
+				bl.Close()
+				if !bl.Closed() {
+					t.Error("Closed() = false after Close")
+				}
+
+				var wg sync.WaitGroup
+				for i := 0; i < 10; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						bl.Info("late")
+					}()
+				}
+				wg.Wait()
+				if out.String() != "" {
+					t.Errorf("written after Close: %q", out.String())
+				}
+				if n := bl.Stats().AfterClose; n != 10 {
+					t.Errorf("AfterClose = %d, want 10", n)
+				}
+				want := 0
+				if warn {
+					want = 1
+				}
+				if n := strings.Count(errOut.String(), "after Close"); n != want {
+					t.Errorf("error output %q, want %d warnings", errOut.String(), want)
+				}
+			})
+		}
+	}
+}
+
+func TestAsyncWorkers(t *testing.T) {
+	for _, workers := range []int64{1, 4} {
+		t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+			bl := NewLogger()
+			var out syncBuffer
+			bl.AddWriter(&out, LevelDebug)
+			bl.Async(16, workers)
+			for i := 0; i < 500; i++ {
+				bl.Info("msg %d", i)
+			}
+			// Flush returns once every worker has drained the channel.
+			bl.Flush()
+			lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+			if len(lines) != 500 {
+				t.Fatalf("%d lines after Flush, want 500", len(lines))
+			}
+			seen := make(map[string]bool)
+			inOrder := true
+			for i, line := range lines {
+				msg := line[strings.Index(line, "msg "):]
+				seen[msg] = true
+				inOrder = inOrder && msg == fmt.Sprintf("msg %d", i)
+			}
+			if len(seen) != 500 {
+				t.Errorf("%d distinct messages, want 500", len(seen))
+			}
+			// One worker keeps the logging order; more may not.
+			if workers == 1 && !inOrder {
+				t.Error("messages out of order with one worker")
+			}
+			bl.Close()
+		})
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	tests := []struct {
+		name   string
+		level  int // of the logger
+		output int // level of the only output
+		want   map[int]bool
+	}{
+		{"all", LevelDebug, LevelDebug, map[int]bool{LevelError: true, LevelInfo: true, LevelDebug: true}},
+		{"logger level", LevelInfo, LevelDebug, map[int]bool{LevelError: true, LevelInfo: true, LevelDebug: false}},
+		{"output level", LevelDebug, LevelError, map[int]bool{LevelError: true, LevelInfo: false, LevelDebug: false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bl := NewLogger()
+			if err := bl.SetLogger(AdapterMemory, fmt.Sprintf(`{"level":%d}`, tt.output)); err != nil {
+				t.Fatal(err)
+			}
+			bl.SetLevel(tt.level)
+			for level, want := range tt.want {
+				if got := bl.Enabled(level); got != want {
+					t.Errorf("Enabled(%s) = %v, want %v", LevelName(level), got, want)
+				}
+			}
+
+			// LevelTrace is LevelDebug, so both or neither call f.
+			called := 0
+			f := func() string { called++; return "built" }
+			bl.DebugFunc(f)
+			bl.TraceFunc(f)
+			want := 0
+			if tt.want[LevelDebug] {
+				want = 2
+			}
+			if called != want {
+				t.Errorf("closure called %d times, want %d", called, want)
+			}
+		})
+	}
+}
+
+// TestFlushTimeout flushes an async logger whose output is stuck in a
+// write: FlushTimeout gives up, and succeeds once the write goes through.
+func TestFlushTimeout(t *testing.T) {
+	bl := NewLogger()
+	w := newBlockWriter()
+	bl.AddWriter(w, LevelDebug)
+	bl.Async()
+	defer bl.Close()
+
+	if err := bl.FlushTimeout(time.Second); err != nil {
+		t.Fatalf("FlushTimeout with nothing pending: %v", err)
+	}
+	bl.Info("stuck")
+	<-w.started
+	start := time.Now()
+	if err := bl.FlushTimeout(50 * time.Millisecond); err == nil {
+		t.Error("FlushTimeout on a stuck output returned no error")
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("FlushTimeout returned after %s", d)
+	}
+
+	close(w.release)
+	if err := bl.FlushTimeout(5 * time.Second); err != nil {
+		t.Errorf("FlushTimeout after release: %v", err)
+	}
+	if !strings.Contains(w.String(), "stuck") {
+		t.Errorf("output %q lacks the flushed line", w.String())
+	}
+}
+
+func TestCallerTrim(t *testing.T) {
+	const file = "/home/dev/project/internal/handler/user.go"
+	tests := []struct {
+		trim int
+		root string
+		want string
+	}{
+		{1, "", "user.go"},
+		{2, "", "handler/user.go"},
+		{3, "", "internal/handler/user.go"},
+		{0, "", file},
+		{10, "", file},
+		{1, "/home/dev/project", "internal/handler/user.go"},
+		{1, "/home/dev/project/", "internal/handler/user.go"},
+		{2, "/elsewhere", "handler/user.go"}, // outside root: trimmed
+		{1, "/home/dev/proj", "user.go"},     // a root must be a whole directory
+	}
+	for _, tt := range tests {
+		bl := NewLogger()
+		bl.SetCallerTrim(tt.trim)
+		bl.SetCallerRoot(tt.root)
+		if got := bl.callerFile(file); got != tt.want {
+			t.Errorf("trim %d, root %q: %q, want %q", tt.trim, tt.root, got, tt.want)
+		}
+	}
+
+	// End to end, with the root set to the directory of this file.
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bl := NewLogger()
+	var out syncBuffer
+	bl.AddWriter(&out, LevelDebug)
+	bl.EnableFuncCallDepth(true)
+	bl.SetCallerTrim(2)
+	bl.Info("trimmed")
+	trimmed := thisLine() - 1
+	bl.SetCallerRoot(wd)
+	bl.Info("rooted")
+	rooted := thisLine() - 1
+	for _, want := range []string{
+		fmt.Sprintf("[%s/log_test.go:%d]trimmed", filepath.Base(wd), trimmed),
+		fmt.Sprintf("[log_test.go:%d]rooted", rooted),
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, out.String())
+		}
+	}
+}
+
+// TestAsyncSwitchOrder switches to async while goroutines log: each one's
+// messages come out complete and in the order it logged them.
+func TestAsyncSwitchOrder(t *testing.T) {
+	const goroutines, msgs = 4, 400
+	bl := NewLogger()
+	var out syncBuffer
+	bl.AddWriter(&out, LevelDebug)
+
+	var wg sync.WaitGroup
+	half := make(chan struct{}, goroutines)
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < msgs; i++ {
+				if i == msgs/2 {
+					half <- struct{}{}
+				}
+				bl.Info("g%d %d", g, i)
+			}
+		}(g)
+	}
+	for g := 0; g < goroutines; g++ {
+		<-half
+	}
+	bl.Async(8)
+	wg.Wait()
+	bl.Close()
+
+	next := make([]int, goroutines)
+	for _, line := range strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n") {
+		var g, i int
+		if _, err := fmt.Sscanf(line[strings.Index(line, "] g")+3:], "%d %d", &g, &i); err != nil {
+			t.Fatalf("line %q: %v", line, err)
+		}
+		if i != next[g] {
+			t.Fatalf("goroutine %d: message %d after %d", g, i, next[g]-1)
+		}
+		next[g]++
+	}
+	for g, n := range next {
+		if n != msgs {
+			t.Errorf("goroutine %d: %d messages, want %d", g, n, msgs)
+		}
+	}
+}
+
+func TestNop(t *testing.T) {
+	nop := NewNop()
+	nop.SetLevel(LevelDebug) // no effect
+	calls := []func(){
+		func() { nop.Info("x %d", 1) },
+		func() { nop.Emergency("x") },
+		func() { nop.Errorln("x", 1) },
+		func() { nop.Infow("x", "k", 1) },
+		func() { nop.WriteMsg(LevelError, "x") },
+		func() { nop.Write([]byte("x\n")) },
+	}
+	for i, call := range calls {
+		if n := testing.AllocsPerRun(100, call); n != 0 {
+			t.Errorf("call %d allocates %v times", i, n)
+		}
+	}
+	if nop.Enabled(LevelEmergency) {
+		t.Error("Enabled(LevelEmergency) on a nop logger")
+	}
+	if len(nop.loadOutputs()) != 0 {
+		t.Error("nop logger has outputs")
+	}
+	nop.Flush()
+	nop.Close()
+}
+
+func BenchmarkNop(b *testing.B) {
+	nop := NewNop()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		nop.Info("x %d", 1)
+	}
+}
@@ -15,14 +15,72 @@ func newLogWriter(wr io.Writer) *logWriter {
 	return &logWriter{writer: wr}
 }
 
-func (lg *logWriter) println(when time.Time, msg string) {
+// writeln writes line and its separator with one Write call.
+func (lg *logWriter) writeln(line []byte, sep string) error {
 	lg.Lock()
-	h, _ := formatTimeHeader(when)
-	lg.writer.Write(append(append([]byte(h), msg...), '\n'))
+	_, err := lg.writer.Write(append(line, sep...))
 	lg.Unlock()
+	return err
 }
 
-func formatTimeHeader(when time.Time) (string, int) {
-	now := when.Format("2006-01-02 15:04:05")
-	return now + " ", when.Day()
+func appendTimeHeader(b []byte, when time.Time, layout string) []byte {
+	b = when.AppendFormat(b, layout)
+	return append(b, ' ')
+}
+
+// maxPooledBuf keeps the buffers grown by huge messages out of the pool.
+const maxPooledBuf = 64 << 10
+
+// bufPool holds the buffers messages are assembled in before they are
+// handed to the outputs as a string.
+var bufPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 256)
+		return &b
+	},
+}
+
+// ioWriter is the output added by AddWriter.
+type ioWriter struct {
+	lw        *logWriter
+	formatter Formatter
+	Level     int
+}
+
+// AddWriter adds an output writing text lines up to level to w. Outputs
+// added this way are all named AdapterWriter for DelNamedLogger.
+func (bl *WLogger) AddWriter(w io.Writer, level int) {
+	bl.lock.Lock()
+	defer bl.lock.Unlock()
+	bl.addOutput(newNameLogger(AdapterWriter, &ioWriter{
+		lw:        newLogWriter(w),
+		formatter: TextFormatter{},
+		Level:     level,
+	}))
+	bl.init.Store(true)
+}
+
+func (w *ioWriter) Init(config string) error {
+	return nil
+}
+
+func (w *ioWriter) enabled(level int) bool {
+	return !filtered(level, w.Level)
+}
+
+func (w *ioWriter) WriteMsg(when time.Time, msg string, level int) error {
+	return w.writeRecord(newTextRecord(when, msg, level))
+}
+
+func (w *ioWriter) writeRecord(r *Record) error {
+	if filtered(r.Level, w.Level) {
+		return nil
+	}
+	return w.lw.writeln(formatRecord(w.formatter, r), "\n")
+}
+
+func (w *ioWriter) Destroy() {
+}
+
+func (w *ioWriter) Flush() {
 }
@@ -0,0 +1,36 @@
+package wlog
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestAddWriter(t *testing.T) {
+	bl := NewLogger()
+	bl.SetClock(&fakeClock{now: time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)})
+	var warn, all bytes.Buffer
+	bl.AddWriter(&warn, LevelWarning)
+	bl.AddWriter(&all, LevelDebug)
+	bl.Debug("detail %d", 1)
+	bl.Warn("low space")
+	bl.Error("disk %s", "full")
+	bl.Close()
+
+	tests := []struct {
+		name string
+		buf  *bytes.Buffer
+		want string
+	}{
+		{"warning", &warn, "2026-03-01 12:00:00 [W] low space\n" +
+			"2026-03-01 12:00:00 [E] disk full\n"},
+		{"debug", &all, "2026-03-01 12:00:00 [D] detail 1\n" +
+			"2026-03-01 12:00:00 [W] low space\n" +
+			"2026-03-01 12:00:00 [E] disk full\n"},
+	}
+	for _, tt := range tests {
+		if got := tt.buf.String(); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+//go:build logr
+
+package wlog
+
+import (
+	"github.com/go-logr/logr"
+)
+
+// LogrSink is a logr.LogSink writing to a WLogger, for use as
+// logr.New(wlog.NewLogrSink(l)). It is built with the logr build tag. V(0)
+// is written at LevelInfo and higher verbosities at LevelDebug. Names given
+// with WithName are joined by "/" into a "logger" field.
+type LogrSink struct {
+	logger *WLogger
+	name   string
+	fields []Field
+	depth  int
+}
+
+func NewLogrSink(l *WLogger) *LogrSink {
+	return &LogrSink{logger: l}
+}
+
+func logrLevel(v int) int {
+	if v <= 0 {
+		return LevelInfo
+	}
+	return LevelDebug
+}
+
+func (s *LogrSink) Init(info logr.RuntimeInfo) {
+	s.depth = info.CallDepth
+}
+
+func (s *LogrSink) Enabled(level int) bool {
+	return s.logger.Enabled(logrLevel(level))
+}
+
+func (s *LogrSink) Info(level int, msg string, keysAndValues ...interface{}) {
+	s.logger.writeMsg(s.depth, logrLevel(level), msg, s.withFields(keysAndValues))
+}
+
+func (s *LogrSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	fields := append(s.withFields(keysAndValues), Field{Key: "error", Value: err})
+	s.logger.writeMsg(s.depth, LevelError, msg, fields)
+}
+
+func (s *LogrSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	c := *s
+	c.fields = make([]Field, 0, len(s.fields)+len(keysAndValues)/2)
+	c.fields = append(c.fields, s.fields...)
+	c.fields = append(c.fields, makeFields(&s.logger.errOut, keysAndValues)...)
+	return &c
+}
+
+func (s *LogrSink) WithName(name string) logr.LogSink {
+	c := *s
+	if c.name != "" {
+		name = c.name + "/" + name
+	}
+	c.name = name
+	return &c
+}
+
+func (s *LogrSink) WithCallDepth(depth int) logr.LogSink {
+	c := *s
+	c.depth += depth
+	return &c
+}
+
+// withFields returns a new slice with the logger name, the fields of s and
+// the given ones.
+func (s *LogrSink) withFields(keysAndValues []interface{}) []Field {
+	fields := make([]Field, 0, 1+len(s.fields)+len(keysAndValues)/2)
+	if s.name != "" {
+		fields = append(fields, Field{Key: "logger", Value: s.name})
+	}
+	fields = append(fields, s.fields...)
+	return append(fields, makeFields(&s.logger.errOut, keysAndValues)...)
+}
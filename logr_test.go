@@ -0,0 +1,50 @@
+//go:build logr
+
+package wlog
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+)
+
+func TestLogrSink(t *testing.T) {
+	tests := []struct {
+		name string
+		log  func(l logr.Logger)
+		want string // empty when filtered
+	}{
+		{"info", func(l logr.Logger) { l.Info("started", "port", 80) }, "[I] started port=80\n"},
+		{"verbose", func(l logr.Logger) { l.V(1).Info("detail") }, "[D] detail\n"},
+		{"error", func(l logr.Logger) { l.Error(errors.New("boom"), "failed", "try", 2) }, "[E] failed try=2 error=\"boom\"\n"},
+		{"values", func(l logr.Logger) { l.WithValues("req", "r1").Info("handled", "code", 200) }, "[I] handled req=r1 code=200\n"},
+		{"names", func(l logr.Logger) { l.WithName("ctrl").WithName("pod").Info("synced") }, "[I] synced logger=ctrl/pod\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bl := NewLogger()
+			var out syncBuffer
+			bl.AddWriter(&out, LevelDebug)
+			tt.log(logr.New(NewLogrSink(bl)))
+			if got := out.String(); !strings.HasSuffix(got, " "+tt.want) {
+				t.Errorf("got %q, want suffix %q", got, tt.want)
+			}
+		})
+	}
+
+	// At LevelInfo, V(1) is disabled.
+	bl := NewLogger()
+	var out syncBuffer
+	bl.AddWriter(&out, LevelDebug)
+	bl.SetLevel(LevelInfo)
+	l := logr.New(NewLogrSink(bl))
+	if l.V(1).Enabled() {
+		t.Error("V(1) enabled at LevelInfo")
+	}
+	l.V(1).Info("hidden")
+	if out.String() != "" {
+		t.Errorf("V(1) logged %q at LevelInfo", out.String())
+	}
+}
@@ -0,0 +1,97 @@
+package wlog
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// LoggedMessage is a message recorded by a MemoryWriter. Msg holds the
+// line as written by the text outputs, without the fields.
+type LoggedMessage struct {
+	When   time.Time
+	Level  int
+	Msg    string
+	Fields []Field
+}
+
+// MemoryWriter is an adapter keeping every message in memory, meant for
+// asserting on log output in tests.
+type MemoryWriter struct {
+	mu       sync.Mutex
+	messages []LoggedMessage
+
+	Level int `json:"level"`
+}
+
+func newMemoryWriter() Logger {
+	return &MemoryWriter{Level: LevelTrace}
+}
+
+// NewMemoryLogger returns a logger writing only to the returned MemoryWriter.
+func NewMemoryLogger() (*WLogger, *MemoryWriter) {
+	m := &MemoryWriter{Level: LevelTrace}
+	bl := NewLogger()
+	bl.addOutput(newNameLogger(AdapterMemory, m))
+	bl.init.Store(true)
+	return bl, m
+}
+
+func (m *MemoryWriter) Init(jsonConfig string) error {
+	if len(jsonConfig) == 0 {
+		return nil
+	}
+	return json.Unmarshal([]byte(jsonConfig), m)
+}
+
+func (m *MemoryWriter) setup() error {
+	return nil
+}
+
+func (m *MemoryWriter) enabled(level int) bool {
+	return !filtered(level, m.Level)
+}
+
+func (m *MemoryWriter) WriteMsg(when time.Time, msg string, level int) error {
+	if filtered(level, m.Level) {
+		return nil
+	}
+	m.mu.Lock()
+	m.messages = append(m.messages, LoggedMessage{When: when, Level: level, Msg: msg})
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *MemoryWriter) WriteRecord(r Record) error {
+	if filtered(r.Level, m.Level) {
+		return nil
+	}
+	m.mu.Lock()
+	m.messages = append(m.messages, LoggedMessage{When: r.Time, Level: r.Level, Msg: r.text(), Fields: r.Fields})
+	m.mu.Unlock()
+	return nil
+}
+
+// Messages returns a copy of the messages written so far.
+func (m *MemoryWriter) Messages() []LoggedMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]LoggedMessage(nil), m.messages...)
+}
+
+// Reset discards the messages written so far.
+func (m *MemoryWriter) Reset() {
+	m.mu.Lock()
+	m.messages = nil
+	m.mu.Unlock()
+}
+
+func (m *MemoryWriter) Destroy() {
+}
+
+func (m *MemoryWriter) Flush() {
+}
+
+func init() {
+	Register(AdapterMemory, newMemoryWriter)
+}
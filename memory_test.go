@@ -0,0 +1,41 @@
+package wlog
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMemoryLogger(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)}
+	bl, m := NewMemoryLogger()
+	bl.SetClock(clock)
+	bl.Info("hello %d", 1)
+	bl.With("k", "v").Error("failed")
+
+	want := []LoggedMessage{
+		{When: clock.now, Level: LevelInfo, Msg: "[I] hello 1"},
+		{When: clock.now, Level: LevelError, Msg: "[E] failed", Fields: []Field{{Key: "k", Value: "v"}}},
+	}
+	if got := m.Messages(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Messages =\n%+v\nwant\n%+v", got, want)
+	}
+	m.Reset()
+	if got := m.Messages(); len(got) != 0 {
+		t.Errorf("Messages after Reset = %v", got)
+	}
+}
+
+func TestMemoryAdapterLevel(t *testing.T) {
+	bl := NewLogger()
+	if err := bl.SetLogger(AdapterMemory, fmt.Sprintf(`{"level":%d}`, LevelWarning)); err != nil {
+		t.Fatal(err)
+	}
+	bl.Info("dropped")
+	bl.Warn("kept")
+	m := output(t, bl, AdapterMemory).(*MemoryWriter)
+	if got := m.Messages(); len(got) != 1 || got[0].Msg != "[W] kept" {
+		t.Errorf("Messages = %+v, want the warning alone", got)
+	}
+}
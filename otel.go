@@ -0,0 +1,23 @@
+//go:build otel
+
+package wlog
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// With the otel build tag, the *Context methods add the trace_id and
+// span_id of the span in the context.
+func init() {
+	RegisterContextFunc(otelFields)
+}
+
+func otelFields(ctx context.Context) []interface{} {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return nil
+	}
+	return []interface{}{"trace_id", sc.TraceID().String(), "span_id", sc.SpanID().String()}
+}
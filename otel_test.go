@@ -0,0 +1,40 @@
+//go:build otel
+
+package wlog
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestOtelFields(t *testing.T) {
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10},
+		SpanID:     trace.SpanID{0xa1, 0xa2, 0xa3, 0xa4, 0xa5, 0xa6, 0xa7, 0xa8},
+		TraceFlags: trace.FlagsSampled,
+	})
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want string
+	}{
+		{"span", trace.ContextWithSpanContext(context.Background(), sc),
+			"[I] hello trace_id=0102030405060708090a0b0c0d0e0f10 span_id=a1a2a3a4a5a6a7a8\n"},
+		{"no span", context.Background(), "[I] hello\n"},
+		{"invalid span", trace.ContextWithSpanContext(context.Background(), trace.SpanContext{}), "[I] hello\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bl := NewLogger()
+			var out syncBuffer
+			bl.AddWriter(&out, LevelDebug)
+			bl.InfoContext(tt.ctx, "hello")
+			if got := out.String(); !strings.HasSuffix(got, tt.want) {
+				t.Errorf("got %q, want suffix %q", got, tt.want)
+			}
+		})
+	}
+}
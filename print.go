@@ -0,0 +1,81 @@
+package wlog
+
+// The *ln methods format their operands like fmt.Sprintln, without the
+// trailing newline, so no argument is read as a format.
+
+func (bl *WLogger) Emergencyln(v ...interface{}) {
+	if LevelEmergency > int(bl.level.Load()) {
+		return
+	}
+	bl.writeMsg(0, LevelEmergency, sprintln(v...), nil)
+}
+
+func (bl *WLogger) Alertln(v ...interface{}) {
+	if LevelAlert > int(bl.level.Load()) {
+		return
+	}
+	bl.writeMsg(0, LevelAlert, sprintln(v...), nil)
+}
+
+func (bl *WLogger) Criticalln(v ...interface{}) {
+	if LevelCritical > int(bl.level.Load()) {
+		return
+	}
+	bl.writeMsg(0, LevelCritical, sprintln(v...), nil)
+}
+
+func (bl *WLogger) Errorln(v ...interface{}) {
+	if LevelError > int(bl.level.Load()) {
+		return
+	}
+	bl.writeMsg(0, LevelError, sprintln(v...), nil)
+}
+
+func (bl *WLogger) Warningln(v ...interface{}) {
+	if LevelWarning > int(bl.level.Load()) {
+		return
+	}
+	bl.writeMsg(0, LevelWarning, sprintln(v...), nil)
+}
+
+func (bl *WLogger) Noticeln(v ...interface{}) {
+	if LevelNotice > int(bl.level.Load()) {
+		return
+	}
+	bl.writeMsg(0, LevelNotice, sprintln(v...), nil)
+}
+
+func (bl *WLogger) Informationalln(v ...interface{}) {
+	if LevelInformational > int(bl.level.Load()) {
+		return
+	}
+	bl.writeMsg(0, LevelInformational, sprintln(v...), nil)
+}
+
+func (bl *WLogger) Debugln(v ...interface{}) {
+	if LevelDebug > int(bl.level.Load()) {
+		return
+	}
+	bl.writeMsg(0, LevelDebug, sprintln(v...), nil)
+}
+
+func (bl *WLogger) Warnln(v ...interface{}) {
+	if LevelWarn > int(bl.level.Load()) {
+		return
+	}
+	bl.writeMsg(0, LevelWarn, sprintln(v...), nil)
+}
+
+func (bl *WLogger) Infoln(v ...interface{}) {
+	if LevelInformational > int(bl.level.Load()) {
+		return
+	}
+	bl.writeMsg(0, LevelInformational, sprintln(v...), nil)
+}
+
+func (bl *WLogger) Traceln(v ...interface{}) {
+	if LevelDebug > int(bl.level.Load()) {
+		return
+	}
+	bl.writeMsg(0, LevelTrace, sprintln(v...), nil)
+}
@@ -0,0 +1,37 @@
+package wlog
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPrintln(t *testing.T) {
+	tests := []struct {
+		log  func(bl *WLogger)
+		want string
+	}{
+		{func(bl *WLogger) { bl.Info("100%% done") }, "[I] 100% done\n"},
+		{func(bl *WLogger) { bl.Infoln("100%", "done") }, "[I] 100% done\n"},
+		{func(bl *WLogger) { verb := "n=%d"; bl.Infoln(verb, 3) }, "[I] n=%d 3\n"},
+		{func(bl *WLogger) { bl.Infoln() }, "[I] \n"},
+		{func(bl *WLogger) { bl.Emergencyln("a", 1) }, "[M] a 1\n"},
+		{func(bl *WLogger) { bl.Alertln("a", 1) }, "[A] a 1\n"},
+		{func(bl *WLogger) { bl.Criticalln("a", 1) }, "[C] a 1\n"},
+		{func(bl *WLogger) { bl.Errorln("a", 1) }, "[E] a 1\n"},
+		{func(bl *WLogger) { bl.Warningln("a", 1) }, "[W] a 1\n"},
+		{func(bl *WLogger) { bl.Warnln("a", 1) }, "[W] a 1\n"},
+		{func(bl *WLogger) { bl.Noticeln("a", 1) }, "[N] a 1\n"},
+		{func(bl *WLogger) { bl.Informationalln("a", 1) }, "[I] a 1\n"},
+		{func(bl *WLogger) { bl.Debugln("a", 1) }, "[D] a 1\n"},
+		{func(bl *WLogger) { bl.Traceln("a", 1) }, "[D] a 1\n"},
+	}
+	for _, tt := range tests {
+		bl := NewLogger()
+		var out syncBuffer
+		bl.AddWriter(&out, LevelDebug)
+		tt.log(bl)
+		if got := out.String(); !strings.HasSuffix(got, " "+tt.want) {
+			t.Errorf("got %q, want suffix %q", got, tt.want)
+		}
+	}
+}
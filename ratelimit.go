@@ -0,0 +1,117 @@
+package wlog
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket refilled at rate tokens per second up to
+// burst. It counts the messages it rejects until one is let through again
+// or the count is taken by pending.
+type rateLimiter struct {
+	sync.Mutex
+	rate       float64
+	burst      float64
+	tokens     float64
+	last       time.Time
+	suppressed int
+}
+
+// allow reports whether a message may be written now and, if so, how many
+// were suppressed since the last one that was. For the first message it
+// suppresses, wait is the time until the next could be let through.
+func (r *rateLimiter) allow(now time.Time) (ok bool, suppressed int, wait time.Duration) {
+	r.Lock()
+	defer r.Unlock()
+	if !r.last.IsZero() {
+		r.tokens += now.Sub(r.last).Seconds() * r.rate
+		if r.tokens > r.burst {
+			r.tokens = r.burst
+		}
+	}
+	r.last = now
+	if r.tokens < 1 {
+		r.suppressed++
+		if r.suppressed == 1 {
+			wait = time.Duration((1 - r.tokens) / r.rate * float64(time.Second))
+		}
+		return false, 0, wait
+	}
+	r.tokens--
+	n := r.suppressed
+	r.suppressed = 0
+	return true, n, 0
+}
+
+// pending returns the suppressed messages not reported yet and forgets
+// them.
+func (r *rateLimiter) pending() int {
+	r.Lock()
+	defer r.Unlock()
+	n := r.suppressed
+	r.suppressed = 0
+	return n
+}
+
+// SetRateLimit caps messages at level to perSecond on average with bursts of
+// up to burst. Messages over the limit are dropped and counted in
+// Stats.Dropped. A notice saying how many were suppressed precedes the
+// next message let through, or is written on its own once one could be,
+// or on Close. A perSecond of 0 removes the limit.
+func (bl *WLogger) SetRateLimit(level int, perSecond float64, burst int) {
+	if level < LevelEmergency || level > LevelDebug {
+		return
+	}
+	if perSecond <= 0 {
+		bl.limiters[level].Store(nil)
+		return
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	bl.limiters[level].Store(&rateLimiter{rate: perSecond, burst: float64(burst), tokens: float64(burst)})
+}
+
+// rateLimit reports whether a message at level may be written and writes
+// the suppression notice when messages were dropped before it.
+func (bl *WLogger) rateLimit(level int, when time.Time) bool {
+	if level < LevelEmergency || level > LevelDebug {
+		return true
+	}
+	r := bl.limiters[level].Load()
+	if r == nil {
+		return true
+	}
+	ok, suppressed, wait := r.allow(when)
+	if !ok {
+		bl.dropped.Add(1)
+		if wait > 0 {
+			due, _ := bl.clock.after(wait)
+			go bl.flushSuppressed(level, r, due)
+		}
+		return false
+	}
+	if suppressed > 0 {
+		bl.suppressedNotice(suppressed, level, when)
+	}
+	return true
+}
+
+// flushSuppressed writes the notice for the messages r suppressed once
+// due fires, unless a message let through wrote it first.
+func (bl *WLogger) flushSuppressed(level int, r *rateLimiter, due <-chan time.Time) {
+	<-due
+	bl.closeLock.RLock()
+	defer bl.closeLock.RUnlock()
+	if bl.closed {
+		return
+	}
+	if n := r.pending(); n > 0 {
+		bl.suppressedNotice(n, level, bl.clock.now().Local())
+	}
+}
+
+func (bl *WLogger) suppressedNotice(suppressed int, level int, when time.Time) {
+	bl.dispatch(bl.getRecord(when, level, strconv.Itoa(suppressed)+" messages suppressed by rate limit"))
+}
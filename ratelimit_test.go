@@ -0,0 +1,90 @@
+package wlog
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimitBurst(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)}
+	bl := NewLogger()
+	bl.SetClock(clock)
+	var out syncBuffer
+	bl.AddWriter(&out, LevelDebug)
+	bl.SetRateLimit(LevelError, 10, 5)
+
+	for i := 0; i < 100; i++ {
+		bl.Error("burst")
+	}
+	bl.Info("not limited")
+	if n := strings.Count(out.String(), "[E] burst\n"); n != 5 {
+		t.Errorf("%d written, want the burst of 5", n)
+	}
+	if n := bl.Stats().Dropped; n != 95 {
+		t.Errorf("Dropped = %d, want 95", n)
+	}
+	if !strings.Contains(out.String(), "not limited") {
+		t.Error("other level limited")
+	}
+
+	// The notice is due once a token is back; half a second refills 5.
+	clock.advance(500 * time.Millisecond)
+	for deadline := time.Now().Add(5 * time.Second); !strings.Contains(out.String(), "95 messages suppressed"); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("no notice:\n%s", out.String())
+		}
+	}
+	for i := 0; i < 10; i++ {
+		bl.Error("after")
+	}
+	bl.Close()
+	if n := strings.Count(out.String(), "[E] after\n"); n != 5 {
+		t.Errorf("%d written after refill, want 5", n)
+	}
+	for _, want := range []string{"[E] 95 messages suppressed by rate limit\n", "[E] 5 messages suppressed by rate limit\n"} {
+		if strings.Count(out.String(), want) != 1 {
+			t.Errorf("want one %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRateLimitNotice(t *testing.T) {
+	tests := []struct {
+		name  string
+		flush func(bl *WLogger, clock *fakeClock)
+	}{
+		{"timer", func(bl *WLogger, clock *fakeClock) {
+			clock.waitTimers(t, 1)
+			clock.advance(time.Second)
+		}},
+		{"close", func(bl *WLogger, clock *fakeClock) { bl.Close() }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &fakeClock{now: time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)}
+			bl := NewLogger()
+			bl.SetClock(clock)
+			var out syncBuffer
+			bl.AddWriter(&out, LevelDebug)
+			bl.SetRateLimit(LevelInfo, 1, 1)
+			for i := 0; i < 3; i++ {
+				bl.Info("msg %d", i)
+			}
+			if s := out.String(); strings.Contains(s, "suppressed") {
+				t.Fatalf("notice written early:\n%s", s)
+			}
+
+			tt.flush(bl, clock)
+			for deadline := time.Now().Add(5 * time.Second); !strings.Contains(out.String(), "2 messages suppressed"); time.Sleep(time.Millisecond) {
+				if time.Now().After(deadline) {
+					t.Fatalf("no notice:\n%s", out.String())
+				}
+			}
+			bl.Close()
+			if n := strings.Count(out.String(), "suppressed"); n != 1 {
+				t.Errorf("%d notices, want 1:\n%s", n, out.String())
+			}
+		})
+	}
+}
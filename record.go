@@ -0,0 +1,87 @@
+package wlog
+
+import (
+	"strings"
+	"time"
+)
+
+// Record is one log event on its way to the outputs, with the message as
+// the caller wrote it and the level, time, caller and fields kept apart.
+type Record struct {
+	Time   time.Time
+	Level  int
+	Msg    string // without level prefix and caller
+	Prefix string // set with SetPrefix, written as "[auth] " in text
+	Caller string // "file.go:12", empty unless EnableFuncCallDepth is on
+	Fields []Field
+
+	raw      bool                    // written through Write, without level prefix
+	prefixes *[LevelDebug + 1]string // set by SetLevelPrefixes, nil for the defaults
+	epoch    *time.Time              // start of TimeRelative stamps, see SetEpoch
+	line     string                  // cached by text
+}
+
+// newTextRecord wraps a line as passed to Logger.WriteMsg, level prefix and
+// caller included, for code that only has the text.
+func newTextRecord(when time.Time, msg string, level int) *Record {
+	return &Record{
+		Time:  when,
+		Level: level,
+		Msg:   strings.TrimPrefix(msg, prefixOf(level)),
+		line:  msg,
+	}
+}
+
+// text renders the record as outputs implementing only WriteMsg receive
+// it, "[I] [file.go:12]msg", without the fields.
+func (r *Record) text() string {
+	if r.line == "" {
+		prefix := ""
+		if !r.raw {
+			prefix = r.prefix()
+		}
+		r.line = r.render(prefix)
+	}
+	return r.line
+}
+
+// body renders the record like text without the level prefix, for outputs
+// with a level of their own such as syslog.
+func (r *Record) body() string {
+	return r.render("")
+}
+
+// prefix returns the level prefix of the record, "[I] " by default.
+func (r *Record) prefix() string {
+	if r.prefixes != nil && Level(r.Level).Valid() {
+		return r.prefixes[r.Level]
+	}
+	return prefixOf(r.Level)
+}
+
+func (r *Record) render(prefix string) string {
+	if prefix == "" && r.Prefix == "" && r.Caller == "" {
+		return r.Msg
+	}
+
+	// Assemble the line in a pooled buffer, copying it out once.
+	buf := bufPool.Get().(*[]byte)
+	b := append((*buf)[:0], prefix...)
+	if r.Prefix != "" {
+		b = append(b, '[')
+		b = append(b, r.Prefix...)
+		b = append(b, "] "...)
+	}
+	if r.Caller != "" {
+		b = append(b, '[')
+		b = append(b, r.Caller...)
+		b = append(b, ']')
+	}
+	b = append(b, r.Msg...)
+	line := string(b)
+	if cap(b) <= maxPooledBuf {
+		*buf = b[:0]
+		bufPool.Put(buf)
+	}
+	return line
+}
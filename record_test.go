@@ -0,0 +1,126 @@
+package wlog
+
+import (
+	"io"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordCollector is an output implementing RecordLogger that keeps the
+// records it gets.
+type recordCollector struct {
+	mu      sync.Mutex
+	records []Record
+}
+
+func (c *recordCollector) Init(config string) error { return nil }
+func (c *recordCollector) Destroy()                 {}
+func (c *recordCollector) Flush()                   {}
+
+func (c *recordCollector) WriteMsg(when time.Time, msg string, level int) error {
+	panic("WriteMsg called on a RecordLogger")
+}
+
+func (c *recordCollector) WriteRecord(r Record) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.records = append(c.records, r)
+	return nil
+}
+
+func (c *recordCollector) Records() []Record {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Record(nil), c.records...)
+}
+
+func init() {
+	Register("testrecords", func() Logger { return &recordCollector{} })
+}
+
+// TestRecordContents checks the record built for a message: the message
+// without prefix, caller or fields, and each of those kept apart.
+func TestRecordContents(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)}
+	bl := NewLogger()
+	bl.SetClock(clock)
+	if err := bl.SetLogger("testrecords"); err != nil {
+		t.Fatal(err)
+	}
+	c := output(t, bl, "testrecords").(*recordCollector)
+
+	bl.SetPrefix("svc")
+	bl.Info("hello %d", 1)
+	bl.EnableFuncCallDepth(true)
+	bl.With("k", "v").Warn("fields")
+	line := thisLine() - 1
+
+	want := []Record{
+		{Time: clock.now, Level: LevelInfo, Msg: "hello 1", Prefix: "svc"},
+		{Time: clock.now, Level: LevelWarn, Msg: "fields", Prefix: "svc",
+			Caller: "record_test.go:" + strconv.Itoa(line), Fields: []Field{{Key: "k", Value: "v"}}},
+	}
+	got := c.Records()
+	if len(got) != len(want) {
+		t.Fatalf("%d records, want %d", len(got), len(want))
+	}
+	for i := range want {
+		g := got[i]
+		g.line, g.prefixes, g.epoch = "", nil, nil
+		if !reflect.DeepEqual(g, want[i]) {
+			t.Errorf("record %d =\n%+v\nwant\n%+v", i, g, want[i])
+		}
+	}
+}
+
+func TestRecordText(t *testing.T) {
+	tests := []struct {
+		name string
+		r    Record
+		want string
+	}{
+		{"plain", Record{Level: LevelInfo, Msg: "msg"}, "[I] msg"},
+		{"prefix", Record{Level: LevelError, Msg: "msg", Prefix: "auth"}, "[E] [auth] msg"},
+		{"caller", Record{Level: LevelWarn, Msg: "msg", Caller: "main.go:12"}, "[W] [main.go:12]msg"},
+		{"both", Record{Level: LevelDebug, Msg: "msg", Prefix: "db", Caller: "db.go:3"}, "[D] [db] [db.go:3]msg"},
+		{"raw", Record{Level: LevelInfo, Msg: "msg", raw: true}, "msg"},
+	}
+	for _, tt := range tests {
+		if got := tt.r.text(); got != tt.want {
+			t.Errorf("%s: text() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func BenchmarkRecordText(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r := Record{Time: time.Now(), Level: LevelInfo, Msg: "benchmark message", Prefix: "svc", Caller: "main.go:12"}
+		_ = r.text()
+	}
+}
+
+// BenchmarkInfo measures a whole sync Info call to a writer, which
+// assembles the line in pooled buffers.
+func BenchmarkInfo(b *testing.B) {
+	for _, caller := range []bool{false, true} {
+		name := "plain"
+		if caller {
+			name = "caller"
+		}
+		b.Run(name, func(b *testing.B) {
+			bl := NewLogger()
+			bl.AddWriter(io.Discard, LevelDebug)
+			bl.SetPrefix("svc")
+			bl.EnableFuncCallDepth(caller)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				bl.Info("benchmark message %d", i)
+			}
+		})
+	}
+}
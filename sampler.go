@@ -0,0 +1,54 @@
+package wlog
+
+import (
+	"sync"
+	"time"
+)
+
+// sampler lets through the first messages with the same text in each
+// second, then every thereafter-th one.
+type sampler struct {
+	sync.Mutex
+	first      int
+	thereafter int
+	tick       time.Time
+	counts     map[string]int
+}
+
+func (s *sampler) allow(msg string, now time.Time) bool {
+	s.Lock()
+	defer s.Unlock()
+	if now.Sub(s.tick) >= time.Second {
+		s.tick = now
+		s.counts = make(map[string]int)
+	}
+	n := s.counts[msg] + 1
+	s.counts[msg] = n
+	if n <= s.first {
+		return true
+	}
+	return s.thereafter > 0 && (n-s.first)%s.thereafter == 0
+}
+
+// SetSampler limits repeated messages at level: within each second, the
+// first messages with the same text are written, then only every
+// thereafter-th one. thereafter 0 drops the rest. Passing first and
+// thereafter both 0 turns sampling off for the level.
+func (bl *WLogger) SetSampler(level int, first int, thereafter int) {
+	if level < LevelEmergency || level > LevelDebug {
+		return
+	}
+	if first <= 0 && thereafter <= 0 {
+		bl.samplers[level].Store(nil)
+		return
+	}
+	bl.samplers[level].Store(&sampler{first: first, thereafter: thereafter})
+}
+
+func (bl *WLogger) sampled(level int, msg string, when time.Time) bool {
+	if level < LevelEmergency || level > LevelDebug {
+		return true
+	}
+	s := bl.samplers[level].Load()
+	return s == nil || s.allow(msg, when)
+}
@@ -0,0 +1,53 @@
+package wlog
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSampler(t *testing.T) {
+	tests := []struct {
+		name              string
+		first, thereafter int
+		want              int
+	}{
+		{"first and every 100th", 10, 100, 19},
+		{"first only", 10, 0, 10},
+		{"every 100th", 0, 100, 10},
+		{"off", 0, 0, 1000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &fakeClock{now: time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)}
+			bl := NewLogger()
+			bl.SetClock(clock)
+			var out syncBuffer
+			bl.AddWriter(&out, LevelDebug)
+			bl.SetSampler(LevelInfo, tt.first, tt.thereafter)
+
+			for i := 0; i < 1000; i++ {
+				bl.Info("same")
+			}
+			bl.Info("other")
+			bl.Warn("same")
+			if n := strings.Count(out.String(), "[I] same\n"); n != tt.want {
+				t.Errorf("%d written, want %d", n, tt.want)
+			}
+			// Other texts and levels are counted apart.
+			if tt.first > 0 && !strings.Contains(out.String(), "[I] other\n") {
+				t.Error("other text sampled away")
+			}
+			if !strings.Contains(out.String(), "[W] same\n") {
+				t.Error("other level sampled")
+			}
+
+			// Counts start over each second.
+			clock.advance(time.Second)
+			bl.Info("same")
+			if n := strings.Count(out.String(), "[I] same\n"); tt.first > 0 && n != tt.want+1 {
+				t.Errorf("%d written after a second, want %d", n, tt.want+1)
+			}
+		})
+	}
+}
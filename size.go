@@ -0,0 +1,58 @@
+package wlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ByteSize is a size in bytes. In JSON it is either a number of bytes or a
+// string such as "512KB", "100MB" or "1GB", where 1KB is 1024 bytes.
+type ByteSize int
+
+var byteSizeUnits = []struct {
+	suffix string
+	size   ByteSize
+}{
+	{"GB", 1 << 30}, {"G", 1 << 30},
+	{"MB", 1 << 20}, {"M", 1 << 20},
+	{"KB", 1 << 10}, {"K", 1 << 10},
+	{"B", 1},
+}
+
+// ParseByteSize parses a size like "10MB" or "1024".
+func ParseByteSize(s string) (ByteSize, error) {
+	str := strings.ToUpper(strings.TrimSpace(s))
+	unit := ByteSize(1)
+	for _, u := range byteSizeUnits {
+		if strings.HasSuffix(str, u.suffix) {
+			str = strings.TrimSpace(strings.TrimSuffix(str, u.suffix))
+			unit = u.size
+			break
+		}
+	}
+	n, err := strconv.Atoi(str)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return ByteSize(n) * unit, nil
+}
+
+func (b *ByteSize) UnmarshalJSON(data []byte) error {
+	var n int
+	if err := json.Unmarshal(data, &n); err == nil {
+		*b = ByteSize(n)
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid size %s", data)
+	}
+	size, err := ParseByteSize(s)
+	if err != nil {
+		return err
+	}
+	*b = size
+	return nil
+}
@@ -0,0 +1,37 @@
+package wlog
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestByteSizeJSON(t *testing.T) {
+	tests := []struct {
+		json    string
+		want    ByteSize
+		wantErr bool
+	}{
+		{`"10MB"`, 10 << 20, false},
+		{`"512KB"`, 512 << 10, false},
+		{`"1GB"`, 1 << 30, false},
+		{`"2 mb"`, 2 << 20, false},
+		{`"100"`, 100, false},
+		{`1024`, 1024, false},
+		{`"10XB"`, 0, true},
+		{`"MB"`, 0, true},
+		{`"-1KB"`, 0, true},
+		{`1.5`, 0, true},
+		{`true`, 0, true},
+	}
+	for _, tt := range tests {
+		var b ByteSize
+		err := json.Unmarshal([]byte(tt.json), &b)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.json, err, tt.wantErr)
+			continue
+		}
+		if b != tt.want {
+			t.Errorf("%s = %d, want %d", tt.json, b, tt.want)
+		}
+	}
+}
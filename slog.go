@@ -0,0 +1,85 @@
+//go:build go1.21
+
+package wlog
+
+import (
+	"context"
+	"log/slog"
+)
+
+type slogHandler struct {
+	logger *WLogger
+	fields []Field
+	group  string
+}
+
+// NewSlogHandler returns a slog.Handler writing through l, so that
+// slog.New(wlog.NewSlogHandler(l)) logs to l's outputs. Attributes become
+// fields, with group names joined to their keys by dots, after the fields
+// registered for the context. The time and call site are the record's.
+func NewSlogHandler(l *WLogger) slog.Handler {
+	return &slogHandler{logger: l}
+}
+
+func slogLevel(level slog.Level) int {
+	switch {
+	case level < slog.LevelInfo:
+		return LevelDebug
+	case level < slog.LevelWarn:
+		return LevelInfo
+	case level < slog.LevelError:
+		return LevelWarning
+	default:
+		return LevelError
+	}
+}
+
+func (h *slogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.logger.Enabled(slogLevel(level))
+}
+
+func (h *slogHandler) Handle(ctx context.Context, r slog.Record) error {
+	ctxFields := contextFields(ctx, &h.logger.errOut)
+	fields := make([]Field, len(h.fields), len(h.fields)+len(ctxFields)+r.NumAttrs())
+	copy(fields, h.fields)
+	fields = append(fields, ctxFields...)
+	r.Attrs(func(a slog.Attr) bool {
+		fields = appendSlogAttr(fields, h.group, a)
+		return true
+	})
+	// A record without PC, as built by hand, gets the caller of Handle.
+	return h.logger.writeMsgAt(r.Time, r.PC, 1, slogLevel(r.Level), r.Message, fields)
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := make([]Field, len(h.fields), len(h.fields)+len(attrs))
+	copy(fields, h.fields)
+	for _, a := range attrs {
+		fields = appendSlogAttr(fields, h.group, a)
+	}
+	return &slogHandler{logger: h.logger, fields: fields, group: h.group}
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &slogHandler{logger: h.logger, fields: h.fields, group: h.group + name + "."}
+}
+
+func appendSlogAttr(fields []Field, prefix string, a slog.Attr) []Field {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return fields
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			fields = appendSlogAttr(fields, prefix, ga)
+		}
+		return fields
+	}
+	return append(fields, Field{Key: prefix + a.Key, Value: a.Value.Any()})
+}
@@ -0,0 +1,98 @@
+//go:build go1.21
+
+package wlog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlogHandler(t *testing.T) {
+	bl := NewLogger()
+	var out syncBuffer
+	bl.AddWriter(&out, LevelDebug)
+	bl.EnableFuncCallDepth(true)
+	logger := slog.New(NewSlogHandler(bl))
+
+	tests := []struct {
+		name string
+		log  func() int
+		want string
+	}{
+		{"debug", func() int { logger.Debug("d", "k", 1); return thisLine() }, "[D] [slog_test.go:%d]d k=1"},
+		{"info", func() int { logger.Info("i"); return thisLine() }, "[I] [slog_test.go:%d]i"},
+		{"warn", func() int { logger.Warn("w"); return thisLine() }, "[W] [slog_test.go:%d]w"},
+		{"error", func() int { logger.Error("e"); return thisLine() }, "[E] [slog_test.go:%d]e"},
+		{"with attrs", func() int { logger.With("svc", "api").Info("i", "n", 2); return thisLine() }, "[I] [slog_test.go:%d]i svc=api n=2"},
+		{"group", func() int {
+			logger.WithGroup("req").With("id", 7).Info("i", slog.Group("user", "name", "ann"), "ok", true)
+			return thisLine() - 1
+		}, "[I] [slog_test.go:%d]i req.id=7 req.user.name=ann req.ok=true"},
+		{"empty group", func() int { logger.WithGroup("").Info("i", slog.Group("", "a", 1)); return thisLine() }, "[I] [slog_test.go:%d]i a=1"},
+	}
+	for _, tt := range tests {
+		line := tt.log()
+		want := fmt.Sprintf(tt.want, line) + "\n"
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("%s: output lacks %q:\n%s", tt.name, want, out.String())
+		}
+	}
+
+	bl.SetLevel(LevelWarning)
+	if logger.Enabled(context.Background(), slog.LevelInfo) || !logger.Enabled(context.Background(), slog.LevelWarn) {
+		t.Error("Enabled ignores the logger's level")
+	}
+}
+
+// wrappedHandler stands for a handler that wraps the one of wlog, adding
+// its own frame between slog and Handle.
+type wrappedHandler struct{ slog.Handler }
+
+func (h wrappedHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.Handler.Handle(ctx, r)
+}
+
+// TestSlogRecord checks that the caller, time and context fields come from
+// the record and its context, whatever wraps the handler.
+func TestSlogRecord(t *testing.T) {
+	resetContextRegistry(t)
+	RegisterContextKey(testCtxKey{}, "trace_id")
+	bl := NewLogger()
+	var out syncBuffer
+	bl.AddWriter(&out, LevelDebug)
+	bl.EnableFuncCallDepth(true)
+	bl.SetClock(&fakeClock{now: time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)})
+	logger := slog.New(wrappedHandler{NewSlogHandler(bl).WithAttrs([]slog.Attr{slog.Int("svc", 1)})})
+
+	ctx := context.WithValue(context.Background(), testCtxKey{}, "abc")
+	logger.InfoContext(ctx, "wrapped", "n", 2)
+	line := thisLine() - 1
+	if want := fmt.Sprintf("[I] [slog_test.go:%d]wrapped svc=1 trace_id=abc n=2\n", line); !strings.Contains(out.String(), want) {
+		t.Errorf("output lacks %q:\n%s", want, out.String())
+	}
+
+	when := time.Date(2025, 7, 4, 9, 30, 0, 0, time.Local)
+	r := slog.NewRecord(when, slog.LevelWarn, "stamped", 0)
+	if err := logger.Handler().Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	if want := "2025-07-04 09:30:00 [W] "; !strings.Contains(out.String(), want) {
+		t.Errorf("output lacks the record time %q:\n%s", want, out.String())
+	}
+}
+
+// TestSlogEnabled checks that Enabled follows the outputs' levels as well
+// as the logger's.
+func TestSlogEnabled(t *testing.T) {
+	bl := NewLogger()
+	bl.AddWriter(io.Discard, LevelWarning)
+	h := NewSlogHandler(bl)
+	if h.Enabled(context.Background(), slog.LevelInfo) || !h.Enabled(context.Background(), slog.LevelError) {
+		t.Error("Enabled ignores the output's level")
+	}
+}
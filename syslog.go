@@ -0,0 +1,205 @@
+package wlog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3,
+	"auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+var syslogLocalSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// syslogWriter sends RFC 5424 messages to a syslog daemon. wlog levels map
+// one to one onto syslog severities. Messages go to stderr while the daemon
+// cannot be reached.
+type syslogWriter struct {
+	sync.Mutex
+	conn      net.Conn
+	destroyed bool // set by Destroy, no connection is made after it
+	stream    bool
+	hostname  string
+	priority  int
+	fallback  *logWriter
+	errOut    errorOutput
+
+	Network     string `json:"network"`
+	Address     string `json:"address"`
+	DialTimeout int    `json:"dialtimeout"` // milliseconds, 0 means none
+	Facility    string `json:"facility"`
+	Tag         string `json:"tag"`
+	Level       int    `json:"level"`
+}
+
+func newSyslogWriter() Logger {
+	return &syslogWriter{
+		fallback:    newLogWriter(os.Stderr),
+		DialTimeout: 1000,
+		Facility:    "user",
+		Tag:         filepath.Base(os.Args[0]),
+		Level:       LevelTrace,
+	}
+}
+
+func (s *syslogWriter) setErrorOutput(w io.Writer) {
+	s.errOut.set(w)
+}
+
+func (s *syslogWriter) Init(jsonConfig string) error {
+	if len(jsonConfig) > 0 {
+		if err := json.Unmarshal([]byte(jsonConfig), s); err != nil {
+			return err
+		}
+	}
+	return s.setup()
+}
+
+func (s *syslogWriter) setup() error {
+	facility, ok := syslogFacilities[strings.ToLower(s.Facility)]
+	if !ok {
+		return fmt.Errorf("syslog: unknown facility %q", s.Facility)
+	}
+	s.priority = facility * 8
+	if s.Network != "" && s.Address == "" {
+		return errors.New("syslog: network set without address")
+	}
+
+	s.hostname, _ = os.Hostname()
+	if s.hostname == "" {
+		s.hostname = "-"
+	}
+	if s.Tag == "" {
+		s.Tag = "-"
+	}
+
+	if err := s.connect(); err != nil {
+		s.errOut.printf("syslog: %s, falling back to stderr\n", err)
+	}
+	return nil
+}
+
+func (s *syslogWriter) connect() error {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+
+	timeout := time.Duration(s.DialTimeout) * time.Millisecond
+	if s.Network != "" {
+		conn, err := net.DialTimeout(s.Network, s.Address, timeout)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+		s.stream = !strings.HasPrefix(s.Network, "udp") && s.Network != "unixgram"
+		return nil
+	}
+
+	for _, network := range []string{"unixgram", "unix"} {
+		for _, path := range syslogLocalSockets {
+			conn, err := net.DialTimeout(network, path, timeout)
+			if err == nil {
+				s.conn = conn
+				s.stream = network == "unix"
+				return nil
+			}
+		}
+	}
+	return errors.New("no local syslog socket found")
+}
+
+func (s *syslogWriter) enabled(level int) bool {
+	return !filtered(level, s.Level)
+}
+
+func (s *syslogWriter) WriteMsg(when time.Time, msg string, level int) error {
+	return s.writeRecord(newTextRecord(when, msg, level))
+}
+
+// writeRecord leaves out the level prefix, syslog has a severity of its own.
+func (s *syslogWriter) writeRecord(r *Record) error {
+	if filtered(r.Level, s.Level) {
+		return nil
+	}
+
+	when, level := r.Time, r.Level
+	msg := r.body() + fieldsText(r.Fields)
+	severity := level
+	if !Level(level).Valid() {
+		// Keep custom levels from spilling into the facility bits.
+		severity = LevelDebug
+	}
+	line := fmt.Sprintf("<%d>1 %s %s %s %d - - %s",
+		s.priority+severity, when.Format("2006-01-02T15:04:05.000000Z07:00"), s.hostname, s.Tag, os.Getpid(), msg)
+	if s.stream {
+		line += "\n"
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	if s.destroyed {
+		return os.ErrClosed
+	}
+	err := s.write(line)
+	if err != nil {
+		if err = s.connect(); err == nil {
+			err = s.write(line)
+		}
+	}
+	if err != nil {
+		s.fallback.writeln([]byte(strings.TrimSuffix(line, "\n")), "\n")
+	}
+	return nil
+}
+
+func (s *syslogWriter) write(line string) error {
+	if s.conn == nil {
+		return errors.New("syslog: not connected")
+	}
+	_, err := s.conn.Write([]byte(line))
+	return err
+}
+
+// HealthCheck connects to the daemon if the writer is falling back to
+// stderr.
+func (s *syslogWriter) HealthCheck() error {
+	s.Lock()
+	defer s.Unlock()
+	if s.destroyed {
+		return os.ErrClosed
+	}
+	if s.conn != nil {
+		return nil
+	}
+	return s.connect()
+}
+
+func (s *syslogWriter) Destroy() {
+	s.Lock()
+	defer s.Unlock()
+	s.destroyed = true
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+func (s *syslogWriter) Flush() {
+}
+
+func init() {
+	Register(AdapterSyslog, newSyslogWriter)
+}
@@ -0,0 +1,36 @@
+package wlog
+
+import (
+	"strconv"
+	"unicode/utf8"
+)
+
+// SetMaxMessageBytes cuts messages longer than n bytes, fields included,
+// ending them with "…[truncated N bytes]". A truncated message has its
+// fields rendered as text into the message. Zero, the default, means no
+// limit.
+func (bl *WLogger) SetMaxMessageBytes(n int) {
+	bl.maxMessageBytes.Store(int64(n))
+}
+
+// truncate applies the SetMaxMessageBytes limit to msg and fields.
+func (bl *WLogger) truncate(msg string, fields []Field) (string, []Field) {
+	max := int(bl.maxMessageBytes.Load())
+	if max <= 0 || len(msg) <= max && len(fields) == 0 {
+		return msg, fields
+	}
+	if len(fields) > 0 {
+		if text := fieldsText(fields); len(msg)+len(text) > max {
+			msg += text
+			fields = nil
+		}
+	}
+	if len(msg) <= max {
+		return msg, fields
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(msg[cut]) {
+		cut--
+	}
+	return msg[:cut] + "…[truncated " + strconv.Itoa(len(msg)-cut) + " bytes]", fields
+}
@@ -0,0 +1,46 @@
+package wlog
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTruncate(t *testing.T) {
+	big := strings.Repeat("b", 1<<20)
+	tests := []struct {
+		name string
+		max  int
+		log  func(bl *WLogger)
+		want string // suffix of the line
+	}{
+		{"1MB", 100, func(bl *WLogger) { bl.Info("%s", big) },
+			strings.Repeat("b", 100) + "…[truncated 1048476 bytes]\n"},
+		{"short", 100, func(bl *WLogger) { bl.Info("short") }, "[I] short\n"},
+		{"unlimited", 0, func(bl *WLogger) { bl.Info("%s", big) }, big + "\n"},
+		{"fields kept", 100, func(bl *WLogger) { bl.Infow("msg", "k", "v") }, "[I] msg k=v\n"},
+		{"fields cut", 10, func(bl *WLogger) { bl.Infow("message", "key", "value") },
+			"[I] message ke…[truncated 7 bytes]\n"},
+		// The cut does not split the three-byte "€".
+		{"utf8", 4, func(bl *WLogger) { bl.Info("ab€€") }, "[I] ab…[truncated 6 bytes]\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bl := NewLogger()
+			var out syncBuffer
+			bl.AddWriter(&out, LevelDebug)
+			bl.SetMaxMessageBytes(tt.max)
+			tt.log(bl)
+			if got := out.String(); !strings.HasSuffix(got, tt.want) {
+				t.Errorf("got %q, want suffix %q", tail(got), tail(tt.want))
+			}
+		})
+	}
+}
+
+// tail shortens s to its end for error messages.
+func tail(s string) string {
+	if len(s) > 200 {
+		return "..." + s[len(s)-200:]
+	}
+	return s
+}
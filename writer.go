@@ -0,0 +1,52 @@
+package wlog
+
+import (
+	"io"
+	"strings"
+)
+
+// LeveledWriter returns an io.Writer that logs each line written to it. A
+// leading level token such as "[ERROR]", "WARN:" or wlog's own "[E]" selects
+// the level and is removed; lines without one use defaultLevel. As with
+// LevelWriter, a trailing partial line waits for its newline.
+func (bl *WLogger) LeveledWriter(defaultLevel int) io.Writer {
+	return &levelWriter{logger: bl, level: defaultLevel, parse: true}
+}
+
+// parseLevelPrefix splits a leading level token off line.
+func parseLevelPrefix(line string, defaultLevel int) (int, string) {
+	s := strings.TrimLeft(line, " \t")
+	if strings.HasPrefix(s, "[") {
+		end := strings.IndexByte(s, ']')
+		if end < 0 {
+			return defaultLevel, line
+		}
+		if level, ok := levelFromToken(s[1:end]); ok {
+			return level, strings.TrimLeft(s[end+1:], " \t")
+		}
+		return defaultLevel, line
+	}
+
+	end := strings.IndexByte(s, ':')
+	if end < 2 {
+		return defaultLevel, line
+	}
+	if level, err := ParseLevel(s[:end]); err == nil {
+		return level, strings.TrimLeft(s[end+1:], " \t")
+	}
+	return defaultLevel, line
+}
+
+// levelFromToken accepts a level name or the letter used in levelPrefix.
+func levelFromToken(token string) (int, bool) {
+	if len(token) == 1 {
+		for level, prefix := range levelPrefix {
+			if prefix[1:2] == strings.ToUpper(token) {
+				return level, true
+			}
+		}
+		return 0, false
+	}
+	level, err := ParseLevel(token)
+	return level, err == nil
+}
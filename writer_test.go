@@ -0,0 +1,57 @@
+package wlog
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseLevelPrefix(t *testing.T) {
+	tests := []struct {
+		line  string
+		level int
+		msg   string
+	}{
+		{"[ERROR] disk full", LevelError, "disk full"},
+		{"[error]disk full", LevelError, "disk full"},
+		{"[E] disk full", LevelError, "disk full"},
+		{"[W] low space", LevelWarning, "low space"},
+		{"WARN: low space", LevelWarning, "low space"},
+		{"  debug: detail", LevelDebug, "detail"},
+		{"[I] started", LevelInfo, "started"},
+		{"no prefix here", LevelNotice, "no prefix here"},
+		{"[unknown] kept", LevelNotice, "[unknown] kept"},
+		{"[unclosed", LevelNotice, "[unclosed"},
+		{"time: 12:00", LevelNotice, "time: 12:00"},
+		{"", LevelNotice, ""},
+	}
+	for _, tt := range tests {
+		level, msg := parseLevelPrefix(tt.line, LevelNotice)
+		if level != tt.level || msg != tt.msg {
+			t.Errorf("parseLevelPrefix(%q) = %d, %q, want %d, %q", tt.line, level, msg, tt.level, tt.msg)
+		}
+	}
+}
+
+func TestLeveledWriter(t *testing.T) {
+	bl := NewLogger()
+	var out syncBuffer
+	bl.AddWriter(&out, LevelDebug)
+	bl.SetLevel(LevelInfo)
+	w := bl.LeveledWriter(LevelInfo)
+
+	input := "[ERROR] one\nplain two\nDEBUG: three\n[W] four\n"
+	if n, err := fmt.Fprint(w, input); err != nil || n != len(input) {
+		t.Fatalf("Write = %d, %v", n, err)
+	}
+	want := "[E] one\n[I] plain two\n[W] four\n"
+	var got []string
+	for _, line := range strings.SplitAfter(out.String(), "\n") {
+		if i := strings.Index(line, "["); i >= 0 {
+			got = append(got, line[i:])
+		}
+	}
+	if strings.Join(got, "") != want {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, ""), want)
+	}
+}